@@ -1,40 +1,74 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"syscall"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/admin"
 	"github.com/rcbadiale/go-rate-limiter/pkg/config"
 	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/metrics"
 	"github.com/rcbadiale/go-rate-limiter/pkg/middlewares"
+	"github.com/rcbadiale/go-rate-limiter/pkg/policy"
 )
 
 func main() {
 	cfg := config.LoadConfig()
-	store := memory.NewMemoryStore()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel}))
+	slog.SetDefault(logger)
+	var opts []memory.Option
+	if cfg.MemoryTTL > 0 {
+		opts = append(opts, memory.WithJanitor(min(cfg.MemoryTTL, time.Minute), cfg.MemoryTTL))
+	}
+	if cfg.MemoryMaxKeys > 0 {
+		opts = append(opts, memory.WithMaxKeys(cfg.MemoryMaxKeys))
+	}
+	store := memory.NewMemoryStore(opts...)
+	defer store.Close()
+	m := metrics.NewMetrics(prometheus.DefaultRegisterer)
+	m.RegisterKeyCount(store)
 
-	ipLimiter := limiter.NewLimiter(store,
-		cfg.IPLimit,
-		cfg.IPDuration,
-	)
-	apiKeyLimiter := limiter.NewLimiter(store,
-		cfg.APIKeyLimit,
-		cfg.APIKeyDuration,
-	)
+	reloader, err := policy.NewReloader(cfg.PolicyFile, store, func(l *limiter.Limiter) {
+		l.SetObserver(m)
+	}, middlewares.WithLogger(logger))
+	if err != nil {
+		slog.Error("error loading policy", slog.Any("error", err))
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	reloader.ReloadOnSignal(ctx, syscall.SIGHUP)
+	if cfg.PolicyWatchInterval > 0 {
+		reloader.Watch(ctx, cfg.PolicyWatchInterval)
+	}
+
+	if cfg.AdminToken != "" {
+		go func() {
+			slog.Info("admin API started", slog.Int("port", cfg.AdminPort))
+			handler := admin.NewHandler(store, reloader, cfg.AdminToken, admin.WithLogger(logger))
+			err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.AdminPort), handler)
+			slog.Error("admin API stopped", slog.Any("error", err))
+		}()
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /hello", helloRoute)
 
-	log.Println("Server started on port 8080")
-	ipRateLimiterMiddleware := middlewares.NewRateLimiterMiddleware(ipLimiter, nil)
-	apiKeyRateLimiterMiddleware := middlewares.NewRateLimiterMiddleware(apiKeyLimiter, keyMapper)
-	mid := ipRateLimiterMiddleware(mux)
-	mid = apiKeyRateLimiterMiddleware(mid)
-	mid = middlewares.LogRequest(mid)
-	http.ListenAndServe(":8080", mid)
-	log.Println("Server stopped")
+	slog.Info("server started", slog.Int("port", 8080))
+	// The metrics are served outside of the rate limiters.
+	root := http.NewServeMux()
+	root.Handle("GET /metrics", promhttp.Handler())
+	root.Handle("/", reloader.Middleware(mux))
+	mid := middlewares.NewLogRequestMiddleware(logger)(root)
+	err = http.ListenAndServe(":8080", mid)
+	slog.Error("server stopped", slog.Any("error", err))
 }
 
 func helloRoute(w http.ResponseWriter, r *http.Request) {
@@ -42,11 +76,3 @@ func helloRoute(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "Welcome to the Rate Limiter API!"}`))
 }
-
-func keyMapper(r *http.Request) string {
-	apiKey := r.Header.Get("API_KEY")
-	if apiKey != "" {
-		return fmt.Sprintf("API_KEY:%v", apiKey)
-	}
-	return ""
-}
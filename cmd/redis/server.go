@@ -1,41 +1,126 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"syscall"
 
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rcbadiale/go-rate-limiter/internal/stores/redis"
+	"github.com/rcbadiale/go-rate-limiter/pkg/admin"
 	"github.com/rcbadiale/go-rate-limiter/pkg/config"
 	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/metrics"
 	"github.com/rcbadiale/go-rate-limiter/pkg/middlewares"
+	"github.com/rcbadiale/go-rate-limiter/pkg/policy"
 )
 
 func main() {
 	cfg := config.LoadConfig()
-	log.Println(cfg)
-	store := redis.NewRedisStore(cfg.RedisAddress, cfg.RedisPassword)
-
-	ipLimiter := limiter.NewLimiter(store,
-		cfg.IPLimit,
-		cfg.IPDuration,
-	)
-	apiKeyLimiter := limiter.NewLimiter(store,
-		cfg.APIKeyLimit,
-		cfg.APIKeyDuration,
-	)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel}))
+	slog.SetDefault(logger)
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		slog.Error("error configuring redis", slog.Any("error", err))
+		os.Exit(1)
+	}
+	store := redis.NewRedisStoreWithClient(client)
+	// The scripts are sent again when missing, so Redis may still be starting
+	if err := store.LoadScripts(context.Background()); err != nil {
+		slog.Warn("error loading redis scripts", slog.Any("error", err))
+	}
+	if cfg.RedisMigrateLegacyKeys {
+		go func() {
+			moved, err := store.MigrateLegacyKeys(context.Background(), cfg.RedisLegacyKeyPrefixes, cfg.RedisLegacyTTL)
+			if err != nil {
+				slog.Error("error migrating legacy redis keys", slog.Int("moved", moved), slog.Any("error", err))
+				return
+			}
+			slog.Info("legacy redis keys migrated", slog.Int("moved", moved))
+		}()
+	}
+	m := metrics.NewMetrics(prometheus.DefaultRegisterer)
+
+	reloader, err := policy.NewReloader(cfg.PolicyFile, store, func(l *limiter.Limiter) {
+		if cfg.StoreFailClosed {
+			l.SetFailurePolicy(limiter.FailClosed)
+		}
+		breaker := limiter.NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+		breaker.SetLogger(logger)
+		l.SetBreaker(breaker)
+		l.SetObserver(m)
+	}, middlewares.WithLogger(logger))
+	if err != nil {
+		slog.Error("error loading policy", slog.Any("error", err))
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	reloader.ReloadOnSignal(ctx, syscall.SIGHUP)
+	if cfg.PolicyWatchInterval > 0 {
+		reloader.Watch(ctx, cfg.PolicyWatchInterval)
+	}
+
+	if cfg.AdminToken != "" {
+		go func() {
+			slog.Info("admin API started", slog.Int("port", cfg.AdminPort))
+			handler := admin.NewHandler(store, reloader, cfg.AdminToken, admin.WithLogger(logger))
+			err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.AdminPort), handler)
+			slog.Error("admin API stopped", slog.Any("error", err))
+		}()
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /hello", helloRoute)
 
-	log.Println("Server started on port 8080")
-	ipRateLimiterMiddleware := middlewares.NewRateLimiterMiddleware(ipLimiter, nil)
-	apiKeyRateLimiterMiddleware := middlewares.NewRateLimiterMiddleware(apiKeyLimiter, keyMapper)
-	mid := ipRateLimiterMiddleware(mux)
-	mid = apiKeyRateLimiterMiddleware(mid)
-	mid = middlewares.LogRequest(mid)
-	http.ListenAndServe(":8080", mid)
-	log.Println("Server stopped")
+	slog.Info("server started", slog.Int("port", 8080))
+	// The metrics are served outside of the rate limiters.
+	root := http.NewServeMux()
+	root.Handle("GET /metrics", promhttp.Handler())
+	root.Handle("/", reloader.Middleware(mux))
+	mid := middlewares.NewLogRequestMiddleware(logger)(root)
+	err = http.ListenAndServe(":8080", mid)
+	slog.Error("server stopped", slog.Any("error", err))
+}
+
+// newRedisClient returns a client for a single Redis node, the Sentinels of
+// a master or a Cluster, as configured.
+func newRedisClient(cfg config.Config) (goredis.UniversalClient, error) {
+	opts := &goredis.UniversalOptions{
+		Addrs:            cfg.RedisAddresses,
+		MasterName:       cfg.RedisMasterName,
+		Username:         cfg.RedisUsername,
+		Password:         cfg.RedisPassword,
+		SentinelPassword: cfg.RedisSentinelPassword,
+		DB:               cfg.RedisDB,
+		PoolSize:         cfg.RedisPoolSize,
+		MinIdleConns:     cfg.RedisMinIdleConns,
+		PoolTimeout:      cfg.RedisPoolTimeout,
+	}
+	if cfg.RedisTLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.RedisTLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+			if err != nil {
+				return nil, err
+			}
+			opts.TLSConfig.RootCAs = x509.NewCertPool()
+			if !opts.TLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.RedisTLSCAFile)
+			}
+		}
+	}
+	// A Cluster reached through a single address is not detected
+	if cfg.RedisCluster && cfg.RedisMasterName == "" {
+		return goredis.NewClusterClient(opts.Cluster()), nil
+	}
+	return goredis.NewUniversalClient(opts), nil
 }
 
 func helloRoute(w http.ResponseWriter, r *http.Request) {
@@ -43,11 +128,3 @@ func helloRoute(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "Welcome to the Rate Limiter API!"}`))
 }
-
-func keyMapper(r *http.Request) string {
-	apiKey := r.Header.Get("API_KEY")
-	if apiKey != "" {
-		return fmt.Sprintf("API_KEY:%v", apiKey)
-	}
-	return ""
-}
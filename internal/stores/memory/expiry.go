@@ -0,0 +1,84 @@
+package memory
+
+import "time"
+
+// Option configures the memory store.
+type Option func(*MemoryStore)
+
+// WithJanitor makes the store drop the keys once they expire, checking them
+// every interval in the background until the store is closed.
+//
+// The logs expire after their window, the theoretical arrival times (TATs)
+// once they are in the past, the slots once their leases expire and the
+// statuses taken by the limiters after the TTL they take them with, while
+// the statuses changed otherwise, which do not know their window, expire
+// after ttl without being changed.
+func WithJanitor(interval, ttl time.Duration) Option {
+	return func(m *MemoryStore) {
+		m.janitorInterval = interval
+		m.ttl = ttl
+	}
+}
+
+// WithMaxKeys caps the number of keys stored, evicting the least recently
+// used keys of each shard past its share of the cap. The shares add up to
+// the cap, so a cap below the number of shards also lowers the shards.
+//
+// An evicted key starts over as a new key, so the cap must be large enough
+// for the keys in use, or the limits would be enforced only partially.
+func WithMaxKeys(maxKeys int) Option {
+	return func(m *MemoryStore) {
+		m.maxKeys = maxKeys
+	}
+}
+
+// WithShards changes the number of shards the keys are spread across,
+// each guarded by its own lock, 32 by default.
+func WithShards(shards int) Option {
+	return func(m *MemoryStore) {
+		m.shardCount = shards
+	}
+}
+
+// statusExpiresAt returns when a status changed now expires.
+func (m *MemoryStore) statusExpiresAt() time.Time {
+	return time.Now().Add(m.ttl)
+}
+
+// dropExpired drops the keys expired at now and returns how many were dropped.
+func (m *MemoryStore) dropExpired(now time.Time) int {
+	dropped := 0
+	for _, s := range m.shards {
+		dropped += s.dropExpired(now)
+	}
+	return dropped
+}
+
+// janitor drops the expired keys every interval until the store is closed.
+func (m *MemoryStore) janitor(interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.dropExpired(time.Now())
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Close stops the janitor of the store, if any.
+//
+// It is safe to call more than once and the store can still be used after it,
+// but the expired keys are no longer dropped.
+func (m *MemoryStore) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		if m.janitorInterval > 0 {
+			<-m.done
+		}
+	})
+	return nil
+}
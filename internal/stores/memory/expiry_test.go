@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// function dropExpired
+
+func (suite *MemoryStoreTestSuite) TestDropExpiredGivenExpiredKeysWhenCallDropExpiredThenTheyAreDropped() {
+	suite.store = NewMemoryStore(WithJanitor(time.Hour, time.Minute))
+	defer suite.store.Close()
+	now := time.Now()
+	suite.store.Increment(ctx, "status")
+	suite.store.Record(ctx, "log", now, time.Second, 5, 1)
+	suite.store.TakeTAT(ctx, "tat", now, 10*time.Second, 0)
+	suite.store.Acquire(ctx, "slots", 1, 30*time.Second)
+
+	suite.Equal(0, suite.store.dropExpired(now))
+	suite.Equal(1, suite.store.dropExpired(now.Add(2*time.Second)))
+	suite.NotContains(suite.store.shard("log").logs, "log")
+	suite.Equal(1, suite.store.dropExpired(now.Add(20*time.Second)))
+	suite.NotContains(suite.store.shard("tat").tats, "tat")
+	suite.Equal(1, suite.store.dropExpired(now.Add(40*time.Second)))
+	suite.NotContains(suite.store.shard("slots").slots, "slots")
+	suite.Equal(1, suite.store.dropExpired(now.Add(2*time.Minute)))
+	suite.NotContains(suite.store.shard("status").statuses, "status")
+	suite.Equal(0, suite.store.Len())
+	for _, sh := range suite.store.shards {
+		suite.Empty(sh.entries)
+	}
+}
+
+func (suite *MemoryStoreTestSuite) TestDropExpiredGivenKeyChangedWhenCallDropExpiredThenExpirationIsExtended() {
+	suite.store = NewMemoryStore(WithJanitor(time.Hour, time.Minute))
+	defer suite.store.Close()
+	now := time.Now()
+	suite.store.Record(ctx, "log", now, time.Second, 5, 1)
+	suite.store.Record(ctx, "log", now.Add(time.Second), time.Second, 5, 1)
+
+	suite.Equal(0, suite.store.dropExpired(now.Add(1500*time.Millisecond)))
+	suite.Equal(1, suite.store.dropExpired(now.Add(2*time.Second)))
+}
+
+func (suite *MemoryStoreTestSuite) TestDropExpiredGivenLimitedKeyWhenCallDropExpiredThenExpirationIsKept() {
+	suite.store = NewMemoryStore(WithJanitor(time.Hour, time.Minute))
+	defer suite.store.Close()
+	now := time.Now()
+	suite.store.TakeTAT(ctx, "tat", now, 10*time.Second, 0)
+	_, moved, _ := suite.store.TakeTAT(ctx, "tat", now.Add(time.Second), 10*time.Second, 0)
+	suite.False(moved)
+
+	suite.Equal(1, suite.store.dropExpired(now.Add(10*time.Second)))
+}
+
+// janitor
+
+func (suite *MemoryStoreTestSuite) TestJanitorGivenExpiredKeysWhenIntervalPassesThenTheyAreDropped() {
+	suite.store = NewMemoryStore(WithJanitor(10*time.Millisecond, 20*time.Millisecond))
+	defer suite.store.Close()
+	suite.store.Increment(ctx, "status")
+
+	suite.Eventually(func() bool {
+		return suite.store.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// function Close
+
+func (suite *MemoryStoreTestSuite) TestCloseGivenJanitorWhenCallCloseThenJanitorStops() {
+	suite.store = NewMemoryStore(WithJanitor(time.Millisecond, time.Millisecond))
+	suite.NoError(suite.store.Close())
+	suite.NoError(suite.store.Close())
+
+	select {
+	case <-suite.store.done:
+	default:
+		suite.Fail("janitor still running")
+	}
+	suite.store.Increment(ctx, "status")
+	time.Sleep(10 * time.Millisecond)
+	suite.Equal(1, suite.store.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestCloseGivenNoJanitorWhenCallCloseThenReturns() {
+	suite.NoError(suite.store.Close())
+}
+
+// WithMaxKeys
+
+func (suite *MemoryStoreTestSuite) TestMaxKeysGivenCapReachedWhenAddingKeyThenLeastRecentlyUsedIsEvicted() {
+	suite.store = NewMemoryStore(WithMaxKeys(2), WithShards(1))
+	now := time.Now()
+	suite.store.Increment(ctx, "key1")
+	suite.store.Record(ctx, "key2", now, time.Minute, 5, 1)
+	// key1 is used again, so key2 becomes the least recently used
+	suite.store.Get(ctx, "key1")
+	suite.store.TakeTAT(ctx, "key3", now, time.Second, 0)
+
+	keys, err := suite.store.Keys(ctx, "")
+	suite.NoError(err)
+	suite.Equal([]string{"key1", "key3"}, keys)
+	suite.NotContains(suite.store.shard("key2").logs, "key2")
+	suite.Equal(2, suite.store.shards[0].lru.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestMaxKeysGivenCapReachedWhenGettingUnknownKeyThenNoKeyIsEvicted() {
+	suite.store = NewMemoryStore(WithMaxKeys(2), WithShards(1))
+	suite.store.Increment(ctx, "key1")
+	suite.store.Increment(ctx, "key2")
+
+	for _, key := range []string{"key3", "key4"} {
+		s, err := suite.store.Get(ctx, key)
+		suite.NoError(err)
+		suite.Equal(0, s.Count)
+	}
+
+	keys, err := suite.store.Keys(ctx, "")
+	suite.NoError(err)
+	suite.Equal([]string{"key1", "key2"}, keys)
+}
+
+func (suite *MemoryStoreTestSuite) TestMaxKeysGivenLimitedKeyWhenAddingKeyThenLimitedKeyIsKept() {
+	suite.store = NewMemoryStore(WithMaxKeys(2), WithShards(1))
+	now := time.Now()
+	suite.store.TakeTAT(ctx, "limited", now, time.Minute, 0)
+	suite.store.TakeTAT(ctx, "key2", now, time.Minute, 0)
+	// The limited key is still in use even if its requests are not allowed
+	_, moved, _ := suite.store.TakeTAT(ctx, "limited", now, time.Minute, 0)
+	suite.False(moved)
+	suite.store.TakeTAT(ctx, "key3", now, time.Minute, 0)
+
+	keys, err := suite.store.Keys(ctx, "")
+	suite.NoError(err)
+	suite.Equal([]string{"key3", "limited"}, keys)
+}
+
+func (suite *MemoryStoreTestSuite) TestDropExpiredGivenStatusTakenWithTTLWhenCallDropExpiredThenItExpiresAfterTheTTL() {
+	suite.store = NewMemoryStore(WithJanitor(time.Hour, time.Hour))
+	defer suite.store.Close()
+	now := time.Now()
+	suite.store.Take(ctx, "status", time.Second, func(s *status.Status) bool {
+		s.Count++
+		return true
+	})
+
+	suite.Equal(0, suite.store.dropExpired(now))
+	suite.Equal(1, suite.store.dropExpired(now.Add(2*time.Second)))
+}
@@ -1,54 +1,309 @@
 package memory
 
 import (
+	"context"
+	"hash/maphash"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/rcbadiale/go-rate-limiter/pkg/status"
 )
 
 // MemoryStore represents a memory store for rate limiter statuses.
+//
+// The keys are spread across shards by their hash, each guarded by its own
+// lock, so requests for different keys rarely wait for each other.
+// Its methods never return an error, they only do so to implement the limiter stores.
 type MemoryStore struct {
-	statuses map[string]*status.Status
-	mu       sync.Mutex
+	shards []*shard
+	seed   maphash.Seed
+
+	shardCount      int
+	ttl             time.Duration
+	maxKeys         int
+	janitorInterval time.Duration
+	stop            chan struct{}
+	done            chan struct{}
+	closeOnce       sync.Once
 }
 
 // NewMemoryStore returns a new memory store.
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		statuses: make(map[string]*status.Status),
+//
+// The keys are kept until they are reset, unless the store is configured
+// to drop them once they expire with WithJanitor or to cap them with
+// WithMaxKeys. A store with a janitor must be closed once no longer used.
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	m := &MemoryStore{
+		seed:       maphash.MakeSeed(),
+		shardCount: defaultShards,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.shardCount = max(m.shardCount, 1)
+	if m.maxKeys > 0 {
+		// Each shard holds at least one key, so there are no more shards than keys
+		m.shardCount = min(m.shardCount, m.maxKeys)
 	}
+	m.shards = make([]*shard, m.shardCount)
+	for i := range m.shards {
+		m.shards[i] = newShard(m.shardMaxKeys(i))
+	}
+	if m.janitorInterval > 0 {
+		go m.janitor(m.janitorInterval)
+	}
+	return m
 }
 
-// Get returns the status of a key.
+// shardMaxKeys returns the share of the cap of the keys held by the i-th
+// shard, the shares adding up to the cap, or zero if the keys are not capped.
+func (m *MemoryStore) shardMaxKeys(i int) int {
+	if m.maxKeys <= 0 {
+		return 0
+	}
+	share := m.maxKeys / m.shardCount
+	if i < m.maxKeys%m.shardCount {
+		share++
+	}
+	return share
+}
+
+// shard returns the shard holding a key.
+func (m *MemoryStore) shard(key string) *shard {
+	return m.shards[maphash.String(m.seed, key)%uint64(len(m.shards))]
+}
+
+// Get returns a copy of the status of a key.
 //
-// If the key does not exist, it resets the status.
-func (m *MemoryStore) Get(key string) *status.Status {
-	s, ok := m.statuses[key]
+// If the key does not exist, it returns a new status without storing it,
+// so looking up unknown keys, as the admin API does, cannot evict the keys
+// stored when the store is capped with WithMaxKeys.
+func (m *MemoryStore) Get(ctx context.Context, key string) (*status.Status, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	s, ok := sh.statuses[key]
 	if !ok {
-		return m.Reset(key)
+		return status.NewStatus(), nil
 	}
-	return s
+	sh.touch(key, time.Time{})
+	copied := *s
+	return &copied, nil
 }
 
 // Increment increments the count of a key.
 //
 // If the key does not exist, it resets the status.
-func (m *MemoryStore) Increment(key string) *status.Status {
-	s := m.Get(key)
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	s.Count += 1
-	return s
+func (m *MemoryStore) Increment(ctx context.Context, key string) (*status.Status, error) {
+	return m.IncrementBy(ctx, key, 1)
+}
+
+// IncrementBy atomically increments the count of a key by n.
+//
+// If the key does not exist, it creates a new status.
+// It returns a copy of the status incremented.
+func (m *MemoryStore) IncrementBy(ctx context.Context, key string, n int) (*status.Status, error) {
+	s, _, err := m.Take(ctx, key, 0, func(s *status.Status) bool {
+		s.Count += n
+		return true
+	})
+	return s, err
 }
 
-// Reset resets the status of a key.
+// Reset resets the status of a key, dropping its log and theoretical
+// arrival time (TAT) as well.
 //
 // If the key does not exist, it creates a new status.
-func (m *MemoryStore) Reset(key string) *status.Status {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *MemoryStore) Reset(ctx context.Context, key string) (*status.Status, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	s := status.NewStatus()
+	sh.statuses[key] = s
+	delete(sh.logs, key)
+	delete(sh.tats, key)
+	sh.touch(key, m.statusExpiresAt())
+	return s, nil
+}
+
+// Keys returns the keys stored starting with the prefix, sorted.
+func (m *MemoryStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys := m.keys()
+	matched := make([]string, 0, len(keys))
+	for key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+		}
+	}
+	slices.Sort(matched)
+	return matched, nil
+}
+
+// Len returns the number of keys stored.
+func (m *MemoryStore) Len() int {
+	return len(m.keys())
+}
+
+// keys returns the set of keys with a status, log, TAT or slots stored.
+func (m *MemoryStore) keys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, sh := range m.shards {
+		sh.keys(keys)
+	}
+	return keys
+}
+
+// Set sets the status of a key.
+//
+// If the key does not exist, it creates it with the given status.
+func (m *MemoryStore) Set(ctx context.Context, key string, s *status.Status) (*status.Status, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.statuses[key] = s
+	sh.touch(key, m.statusExpiresAt())
+	return s, nil
+}
+
+// Take atomically passes the status of a key, or a new status if it does not
+// exist, to the take function, which changes it and returns true to allow
+// the request. The status changed is only stored if it was allowed.
+//
+// The take function is called holding the lock of the shard of the key.
+// The status stored expires after ttl, or the TTL of the janitor if zero.
+// It returns the status changed and true if the request was allowed.
+func (m *MemoryStore) Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
 	s := status.NewStatus()
-	m.statuses[key] = s
-	return s
+	if current, ok := sh.statuses[key]; ok {
+		*s = *current
+	}
+	if !take(s) {
+		sh.touch(key, time.Time{})
+		return s, false, nil
+	}
+	sh.statuses[key] = s
+	if ttl > 0 {
+		sh.touch(key, time.Now().Add(ttl))
+	} else {
+		sh.touch(key, m.statusExpiresAt())
+	}
+	return s, true, nil
+}
+
+// Record adds the request time cost times to the log of a key if at most
+// limit requests would be recorded in the window before it.
+//
+// The log of each key is a ring buffer bounded by the limit.
+// It returns true if the request was recorded, otherwise it returns false
+// and how long until enough of the oldest requests counted leave the window,
+// along with the number of requests in the window.
+func (m *MemoryStore) Record(ctx context.Context, key string, at time.Time, window time.Duration, limit, cost int) (bool, int, time.Duration, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	r, ok := sh.logs[key]
+	if !ok {
+		r = newRing(limit)
+		sh.logs[key] = r
+	}
+	if r.Cap() != limit {
+		r.Resize(limit)
+	}
+	r.Prune(at.Add(-window))
+	sh.touch(key, at.Add(window))
+	if limit <= 0 || cost > limit {
+		return false, r.Len(), window, nil
+	}
+	if excess := r.Len() + cost - limit; excess > 0 {
+		return false, r.Len(), r.At(excess - 1).Add(window).Sub(at), nil
+	}
+	for range cost {
+		r.Push(at)
+	}
+	return true, r.Len(), 0, nil
+}
+
+// LogCount returns the number of requests in the log of a key in the window
+// before now, without changing it.
+func (m *MemoryStore) LogCount(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	r, ok := sh.logs[key]
+	if !ok {
+		return 0, nil
+	}
+	cutoff := now.Add(-window)
+	count := 0
+	for i := range r.Len() {
+		if r.At(i).After(cutoff) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// TAT returns the theoretical arrival time (TAT) of a key, or the zero time
+// if it has none, without changing it.
+func (m *MemoryStore) TAT(ctx context.Context, key string) (time.Time, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.tats[key], nil
+}
+
+// TakeTAT moves the theoretical arrival time (TAT) of a key forward by the
+// interval if it is at most tolerance after now, a TAT in the past or
+// missing is taken as now.
+//
+// It returns the TAT before it was moved and true if it was moved.
+func (m *MemoryStore) TakeTAT(ctx context.Context, key string, now time.Time, interval, tolerance time.Duration) (time.Time, bool, error) {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	tat := sh.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	if tat.Sub(now) > tolerance {
+		sh.touch(key, time.Time{})
+		return tat, false, nil
+	}
+	sh.tats[key] = tat.Add(interval)
+	sh.touch(key, tat.Add(interval))
+	return tat, true, nil
+}
+
+// ReturnTAT moves the theoretical arrival time (TAT) of a key back by the
+// interval if it is after now, but not before now.
+func (m *MemoryStore) ReturnTAT(ctx context.Context, key string, now time.Time, interval time.Duration) error {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	tat, ok := sh.tats[key]
+	if !ok || !tat.After(now) {
+		return nil
+	}
+	tat = tat.Add(-interval)
+	if tat.Before(now) {
+		tat = now
+	}
+	sh.tats[key] = tat
+	sh.touch(key, tat)
+	return nil
 }
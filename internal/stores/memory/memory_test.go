@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -8,6 +10,8 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+var ctx = context.Background()
+
 type MemoryStoreTestSuite struct {
 	suite.Suite
 	store *MemoryStore
@@ -23,94 +27,404 @@ func TestSuite(t *testing.T) {
 
 // function Get
 
-func (suite *MemoryStoreTestSuite) TestGetGivenKeyDoesNotExistsWhenCallGetThenKeyIsCreatedWithDefaultValues() {
+func (suite *MemoryStoreTestSuite) TestGetGivenKeyDoesNotExistsWhenCallGetThenDefaultValuesAreReturnedWithoutStoringTheKey() {
 	key1 := "key"
-	suite.NotContains(suite.store.statuses, key1)
-	status := suite.store.Get(key1)
+	suite.NotContains(suite.store.shard(key1).statuses, key1)
+	status, err := suite.store.Get(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
-	suite.Contains(suite.store.statuses, key1)
-	suite.Equal(0, suite.store.statuses[key1].Count)
-	suite.LessOrEqual(time.Since(suite.store.statuses[key1].StartedAt), time.Second)
+	suite.Equal(0, status.Count)
+	suite.LessOrEqual(time.Since(status.StartedAt), time.Second)
+	suite.NotContains(suite.store.shard(key1).statuses, key1)
+	suite.Zero(suite.store.Len())
 }
 
 func (suite *MemoryStoreTestSuite) TestGetGivenKeysWhenCallGetThenReturnsKeyStatus() {
 	key1 := "key1"
 	status1 := &status.Status{Count: 1, StartedAt: time.Unix(0, 0)}
-	suite.store.statuses[key1] = status1
+	suite.store.shard(key1).statuses[key1] = status1
 
 	key2 := "key2"
 	status2 := &status.Status{Count: 2, StartedAt: time.Unix(1000, 1000)}
-	suite.store.statuses[key2] = status2
+	suite.store.shard(key2).statuses[key2] = status2
 
-	s1 := suite.store.Get(key1)
+	s1, err := suite.store.Get(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(s1)
 	suite.Equal(s1, status1)
-	suite.Equal(1, suite.store.statuses[key1].Count)
-	suite.LessOrEqual(suite.store.statuses[key1].StartedAt, time.Unix(0, 0))
+	suite.Equal(1, suite.store.shard(key1).statuses[key1].Count)
+	suite.LessOrEqual(suite.store.shard(key1).statuses[key1].StartedAt, time.Unix(0, 0))
 
-	s2 := suite.store.Get(key2)
+	s2, err := suite.store.Get(ctx, key2)
+	suite.NoError(err)
 	suite.NotNil(s2)
 	suite.Equal(s2, status2)
-	suite.Equal(2, suite.store.statuses[key2].Count)
-	suite.LessOrEqual(suite.store.statuses[key2].StartedAt, time.Unix(1000, 1000))
+	suite.Equal(2, suite.store.shard(key2).statuses[key2].Count)
+	suite.LessOrEqual(suite.store.shard(key2).statuses[key2].StartedAt, time.Unix(1000, 1000))
 }
 
 // function Increment
 
 func (suite *MemoryStoreTestSuite) TestIncrementGivenKeyDoesNotExistsWhenCallIncrementThenKeyIsCreatedWithCountOne() {
 	key1 := "key"
-	suite.NotContains(suite.store.statuses, key1)
-	status := suite.store.Increment(key1)
+	suite.NotContains(suite.store.shard(key1).statuses, key1)
+	status, err := suite.store.Increment(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
-	suite.Contains(suite.store.statuses, key1)
-	suite.Equal(1, suite.store.statuses[key1].Count)
-	suite.LessOrEqual(time.Since(suite.store.statuses[key1].StartedAt), time.Second)
+	suite.Contains(suite.store.shard(key1).statuses, key1)
+	suite.Equal(1, suite.store.shard(key1).statuses[key1].Count)
+	suite.LessOrEqual(time.Since(suite.store.shard(key1).statuses[key1].StartedAt), time.Second)
 }
 
 func (suite *MemoryStoreTestSuite) TestIncrementGivenKeysWhenCallIncrementThenCountShouldIncreaseAndReturnsKeyStatus() {
 	key1 := "key1"
 	status1 := &status.Status{Count: 1, StartedAt: time.Unix(0, 0)}
-	suite.store.statuses[key1] = status1
+	suite.store.shard(key1).statuses[key1] = status1
 
 	key2 := "key2"
 	status2 := &status.Status{Count: 2, StartedAt: time.Unix(1000, 1000)}
-	suite.store.statuses[key2] = status2
+	suite.store.shard(key2).statuses[key2] = status2
 
-	s1 := suite.store.Increment(key1)
+	s1, err := suite.store.Increment(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(s1)
-	suite.Equal(2, suite.store.statuses[key1].Count)
-	suite.LessOrEqual(suite.store.statuses[key1].StartedAt, time.Unix(0, 0))
+	suite.Equal(2, suite.store.shard(key1).statuses[key1].Count)
+	suite.LessOrEqual(suite.store.shard(key1).statuses[key1].StartedAt, time.Unix(0, 0))
 
-	s2 := suite.store.Increment(key2)
+	s2, err := suite.store.Increment(ctx, key2)
+	suite.NoError(err)
 	suite.NotNil(s2)
-	suite.Equal(3, suite.store.statuses[key2].Count)
-	suite.LessOrEqual(suite.store.statuses[key2].StartedAt, time.Unix(1000, 1000))
+	suite.Equal(3, suite.store.shard(key2).statuses[key2].Count)
+	suite.LessOrEqual(suite.store.shard(key2).statuses[key2].StartedAt, time.Unix(1000, 1000))
+}
+
+// function IncrementBy
+
+func (suite *MemoryStoreTestSuite) TestIncrementByGivenKeyWhenCallIncrementByThenCountIncreasesByN() {
+	key1 := "key"
+	s, err := suite.store.IncrementBy(ctx, key1, 5)
+	suite.NoError(err)
+	suite.Equal(5, s.Count)
+
+	s, err = suite.store.IncrementBy(ctx, key1, 3)
+	suite.NoError(err)
+	suite.Equal(8, s.Count)
+	suite.Equal(8, suite.store.shard(key1).statuses[key1].Count)
 }
 
 // function Reset
 
 func (suite *MemoryStoreTestSuite) TestResetGivenKeyDoesNotExistsWhenCallResetThenKeyIsCreatedWithDefaultValues() {
 	key1 := "key"
-	suite.NotContains(suite.store.statuses, key1)
-	status := suite.store.Reset(key1)
+	suite.NotContains(suite.store.shard(key1).statuses, key1)
+	status, err := suite.store.Reset(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
-	suite.Contains(suite.store.statuses, key1)
-	suite.Equal(0, suite.store.statuses[key1].Count)
-	suite.LessOrEqual(time.Since(suite.store.statuses[key1].StartedAt), time.Second)
+	suite.Contains(suite.store.shard(key1).statuses, key1)
+	suite.Equal(0, suite.store.shard(key1).statuses[key1].Count)
+	suite.LessOrEqual(time.Since(suite.store.shard(key1).statuses[key1].StartedAt), time.Second)
 }
 
 func (suite *MemoryStoreTestSuite) TestResetGivenKeysWhenCallResetThenStatusShouldResetToDefaultValuesAndReturnsKeyStatus() {
 	key := "key1"
 	status := &status.Status{Count: 1, StartedAt: time.Now().Add(-30 * time.Minute)}
-	suite.store.statuses[key] = status
+	suite.store.shard(key).statuses[key] = status
 
-	s := suite.store.Get(key)
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
 	suite.NotNil(s)
-	suite.Equal(1, suite.store.statuses[key].Count)
-	suite.LessOrEqual(time.Since(suite.store.statuses[key].StartedAt), time.Hour)
+	suite.Equal(1, suite.store.shard(key).statuses[key].Count)
+	suite.LessOrEqual(time.Since(suite.store.shard(key).statuses[key].StartedAt), time.Hour)
 
-	s = suite.store.Reset(key)
+	s, err = suite.store.Reset(ctx, key)
+	suite.NoError(err)
 	suite.NotNil(s)
-	suite.Equal(0, suite.store.statuses[key].Count)
-	suite.LessOrEqual(time.Since(suite.store.statuses[key].StartedAt), time.Second)
+	suite.Equal(0, suite.store.shard(key).statuses[key].Count)
+	suite.LessOrEqual(time.Since(suite.store.shard(key).statuses[key].StartedAt), time.Second)
+}
+
+// function Set
+
+func (suite *MemoryStoreTestSuite) TestSetGivenKeyDoesNotExistsWhenCallSetThenKeyIsCreatedWithGivenStatus() {
+	key1 := "key"
+	suite.NotContains(suite.store.shard(key1).statuses, key1)
+	status1 := &status.Status{Count: 3, StartedAt: time.Unix(1000, 1000)}
+	s, err := suite.store.Set(ctx, key1, status1)
+	suite.NoError(err)
+	suite.Equal(status1, s)
+	suite.Contains(suite.store.shard(key1).statuses, key1)
+	suite.Equal(3, suite.store.shard(key1).statuses[key1].Count)
+	suite.Equal(time.Unix(1000, 1000), suite.store.shard(key1).statuses[key1].StartedAt)
+}
+
+func (suite *MemoryStoreTestSuite) TestSetGivenKeyExistsWhenCallSetThenStatusIsReplaced() {
+	key := "key1"
+	suite.store.shard(key).statuses[key] = &status.Status{Count: 1, StartedAt: time.Unix(0, 0)}
+
+	s, err := suite.store.Set(ctx, key, &status.Status{Count: 5, StartedAt: time.Unix(1000, 1000)})
+	suite.NoError(err)
+	suite.NotNil(s)
+	suite.Equal(5, suite.store.shard(key).statuses[key].Count)
+	suite.Equal(time.Unix(1000, 1000), suite.store.shard(key).statuses[key].StartedAt)
+}
+
+func (suite *MemoryStoreTestSuite) TestResetGivenLogAndTATWhenCallResetThenTheyAreDropped() {
+	key := "key1"
+	now := time.Now()
+	suite.store.Record(ctx, key, now, time.Minute, 1, 1)
+	suite.store.TakeTAT(ctx, key, now, time.Minute, 0)
+
+	_, err := suite.store.Reset(ctx, key)
+	suite.NoError(err)
+	suite.NotContains(suite.store.shard(key).logs, key)
+	suite.NotContains(suite.store.shard(key).tats, key)
+}
+
+// function Keys
+
+func (suite *MemoryStoreTestSuite) TestKeysGivenKeysWhenCallKeysThenReturnKeysWithPrefixSorted() {
+	now := time.Now()
+	suite.store.Increment(ctx, "ip:10.0.0.2")
+	suite.store.Record(ctx, "ip:10.0.0.1", now, time.Minute, 1, 1)
+	suite.store.TakeTAT(ctx, "api_key:abc123", now, time.Minute, 0)
+	suite.store.TakeTAT(ctx, "ip:10.0.0.2", now, time.Minute, 0)
+
+	keys, err := suite.store.Keys(ctx, "ip:")
+	suite.NoError(err)
+	suite.Equal([]string{"ip:10.0.0.1", "ip:10.0.0.2"}, keys)
+
+	keys, err = suite.store.Keys(ctx, "")
+	suite.NoError(err)
+	suite.Equal([]string{"api_key:abc123", "ip:10.0.0.1", "ip:10.0.0.2"}, keys)
+}
+
+// function Len
+
+func (suite *MemoryStoreTestSuite) TestLenGivenKeysWhenCallLenThenReturnNumberOfKeys() {
+	suite.Equal(0, suite.store.Len())
+
+	now := time.Now()
+	suite.store.Increment(ctx, "ip:10.0.0.2")
+	suite.store.Record(ctx, "ip:10.0.0.1", now, time.Minute, 1, 1)
+	suite.store.TakeTAT(ctx, "ip:10.0.0.2", now, time.Minute, 0)
+	suite.Equal(2, suite.store.Len())
+}
+
+// function Record
+
+func (suite *MemoryStoreTestSuite) TestRecordGivenKeyDoesNotExistsWhenCallRecordThenLogIsCreatedBoundedByLimit() {
+	key1 := "key"
+	suite.NotContains(suite.store.shard(key1).logs, key1)
+	recorded, count, wait, err := suite.store.Record(ctx, key1, time.Now(), time.Minute, 3, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	suite.Equal(1, count)
+	suite.Zero(wait)
+	suite.Contains(suite.store.shard(key1).logs, key1)
+	suite.Equal(1, suite.store.shard(key1).logs[key1].Len())
+	suite.Equal(3, suite.store.shard(key1).logs[key1].Cap())
+}
+
+func (suite *MemoryStoreTestSuite) TestRecordGivenLimitReachedInWindowWhenCallRecordThenRequestIsNotRecorded() {
+	key := "key1"
+	now := time.Now()
+	recorded, _, _, err := suite.store.Record(ctx, key, now.Add(-30*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(-10*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, count, wait, err := suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(2, count)
+	suite.Equal(30*time.Second, wait)
+
+	// The first request leaves the window after 30 seconds
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(30*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, wait, err = suite.store.Record(ctx, key, now.Add(49*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(time.Second, wait)
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(50*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+}
+
+func (suite *MemoryStoreTestSuite) TestRecordGivenLimitChangedWhenCallRecordThenLogIsResized() {
+	key := "key1"
+	now := time.Now()
+	suite.store.Record(ctx, key, now.Add(-3*time.Second), time.Minute, 3, 1)
+	suite.store.Record(ctx, key, now.Add(-2*time.Second), time.Minute, 3, 1)
+	suite.store.Record(ctx, key, now.Add(-time.Second), time.Minute, 3, 1)
+
+	// The second newest request is the oldest counted with the lower limit
+	recorded, _, wait, err := suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(58*time.Second, wait)
+	suite.Equal(2, suite.store.shard(key).logs[key].Cap())
+	suite.Equal(2, suite.store.shard(key).logs[key].Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestRecordGivenCostWhenCallRecordThenRequestIsRecordedCostTimes() {
+	key := "key1"
+	now := time.Now()
+	recorded, count, _, err := suite.store.Record(ctx, key, now.Add(-30*time.Second), time.Minute, 5, 3)
+	suite.NoError(err)
+	suite.True(recorded)
+	suite.Equal(3, count)
+
+	// The three requests must leave the window for another cost of three
+	recorded, count, wait, err := suite.store.Record(ctx, key, now, time.Minute, 5, 3)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(3, count)
+	suite.Equal(30*time.Second, wait)
+
+	recorded, _, _, err = suite.store.Record(ctx, key, now, time.Minute, 5, 6)
+	suite.NoError(err)
+	suite.False(recorded)
+}
+
+// functions LogCount and TAT
+
+func (suite *MemoryStoreTestSuite) TestLogCountGivenLogWhenCallLogCountThenReturnsRequestsInWindowWithoutChangingIt() {
+	now := time.Now()
+	suite.store.Record(ctx, "key", now.Add(-2*time.Second), time.Minute, 5, 1)
+	suite.store.Record(ctx, "key", now.Add(-time.Second), time.Minute, 5, 2)
+
+	count, err := suite.store.LogCount(ctx, "key", now, 1500*time.Millisecond)
+	suite.NoError(err)
+	suite.Equal(2, count)
+	count, err = suite.store.LogCount(ctx, "key", now, time.Minute)
+	suite.NoError(err)
+	suite.Equal(3, count)
+	count, err = suite.store.LogCount(ctx, "missing", now, time.Minute)
+	suite.NoError(err)
+	suite.Zero(count)
+}
+
+func (suite *MemoryStoreTestSuite) TestTATGivenTATWhenCallTATThenReturnsItWithoutChangingIt() {
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeTAT(ctx, "key", now, time.Second, 0)
+
+	tat, err := suite.store.TAT(ctx, "key")
+	suite.NoError(err)
+	suite.True(now.Add(time.Second).Equal(tat))
+	tat, err = suite.store.TAT(ctx, "key")
+	suite.NoError(err)
+	suite.True(now.Add(time.Second).Equal(tat))
+	tat, err = suite.store.TAT(ctx, "missing")
+	suite.NoError(err)
+	suite.True(tat.IsZero())
+}
+
+// function TakeTAT
+
+func (suite *MemoryStoreTestSuite) TestTakeTATGivenKeyDoesNotExistsWhenCallTakeTATThenTATIsNowAndMoved() {
+	key := "key"
+	now := time.Now()
+	tat, taken, err := suite.store.TakeTAT(ctx, key, now, time.Second, 0)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.Equal(now, tat)
+	suite.Equal(now.Add(time.Second), suite.store.shard(key).tats[key])
+}
+
+func (suite *MemoryStoreTestSuite) TestTakeTATGivenTATAfterToleranceWhenCallTakeTATThenTATIsNotMoved() {
+	key := "key1"
+	now := time.Now()
+	suite.store.shard(key).tats[key] = now.Add(3 * time.Second)
+
+	tat, taken, err := suite.store.TakeTAT(ctx, key, now, time.Second, 2*time.Second)
+	suite.NoError(err)
+	suite.False(taken)
+	suite.Equal(now.Add(3*time.Second), tat)
+	suite.Equal(now.Add(3*time.Second), suite.store.shard(key).tats[key])
+
+	tat, taken, err = suite.store.TakeTAT(ctx, key, now.Add(time.Second), time.Second, 2*time.Second)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.Equal(now.Add(3*time.Second), tat)
+	suite.Equal(now.Add(4*time.Second), suite.store.shard(key).tats[key])
+}
+
+func (suite *MemoryStoreTestSuite) TestTakeTATGivenTATInThePastWhenCallTakeTATThenTATIsTakenAsNow() {
+	key := "key1"
+	now := time.Now()
+	suite.store.shard(key).tats[key] = now.Add(-time.Hour)
+
+	tat, taken, err := suite.store.TakeTAT(ctx, key, now, time.Second, 0)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.Equal(now, tat)
+	suite.Equal(now.Add(time.Second), suite.store.shard(key).tats[key])
+}
+
+// function ReturnTAT
+
+func (suite *MemoryStoreTestSuite) TestReturnTATGivenTATAfterNowWhenCallReturnTATThenTATIsMovedBackNotBeforeNow() {
+	key := "key1"
+	now := time.Now()
+	suite.store.shard(key).tats[key] = now.Add(3 * time.Second)
+
+	suite.NoError(suite.store.ReturnTAT(ctx, key, now, time.Second))
+	suite.Equal(now.Add(2*time.Second), suite.store.shard(key).tats[key])
+	suite.NoError(suite.store.ReturnTAT(ctx, key, now, 5*time.Second))
+	suite.Equal(now, suite.store.shard(key).tats[key])
+}
+
+func (suite *MemoryStoreTestSuite) TestReturnTATGivenKeyDoesNotExistsWhenCallReturnTATThenNothingIsStored() {
+	suite.NoError(suite.store.ReturnTAT(ctx, "key", time.Now(), time.Second))
+	suite.Zero(suite.store.Len())
+}
+
+// function Take
+
+func (suite *MemoryStoreTestSuite) TestTakeGivenKeyDoesNotExistsWhenCallTakeThenNewStatusIsTaken() {
+	key1 := "key"
+	s, allowed, err := suite.store.Take(ctx, key1, time.Minute, func(s *status.Status) bool {
+		suite.Equal(0, s.Count)
+		s.Count++
+		return true
+	})
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(1, s.Count)
+	suite.Equal(1, suite.store.shard(key1).statuses[key1].Count)
+}
+
+func (suite *MemoryStoreTestSuite) TestTakeGivenRequestNotAllowedWhenCallTakeThenStatusIsNotStored() {
+	key := "key1"
+	status1 := &status.Status{Count: 1, StartedAt: time.Unix(0, 0)}
+	suite.store.shard(key).statuses[key] = status1
+
+	s, allowed, err := suite.store.Take(ctx, key, time.Minute, func(s *status.Status) bool {
+		s.Count = 10
+		return false
+	})
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(10, s.Count)
+	suite.Equal(1, suite.store.shard(key).statuses[key].Count)
+}
+
+func (suite *MemoryStoreTestSuite) TestTakeGivenParallelCallsWhenCallTakeThenEachCallSeesThePreviousChange() {
+	key := "key1"
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			suite.store.Take(ctx, key, time.Minute, func(s *status.Status) bool {
+				s.Count++
+				return true
+			})
+		}()
+	}
+	wg.Wait()
+	suite.Equal(100, suite.store.shard(key).statuses[key].Count)
 }
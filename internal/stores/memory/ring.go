@@ -0,0 +1,63 @@
+package memory
+
+import "time"
+
+// ring represents a bounded ring buffer of request times, ordered from
+// the oldest to the newest.
+type ring struct {
+	times []time.Time
+	head  int
+	size  int
+}
+
+// newRing returns a new ring buffer holding up to capacity times.
+func newRing(capacity int) *ring {
+	return &ring{times: make([]time.Time, max(capacity, 0))}
+}
+
+// Len returns the number of times in the ring buffer.
+func (r *ring) Len() int {
+	return r.size
+}
+
+// Cap returns the maximum number of times in the ring buffer.
+func (r *ring) Cap() int {
+	return len(r.times)
+}
+
+// At returns the i-th oldest time in the ring buffer.
+func (r *ring) At(i int) time.Time {
+	return r.times[(r.head+i)%len(r.times)]
+}
+
+// Push adds a time to the ring buffer.
+//
+// If the ring buffer is full, the oldest time is overwritten.
+func (r *ring) Push(t time.Time) {
+	if len(r.times) == 0 {
+		return
+	}
+	r.times[(r.head+r.size)%len(r.times)] = t
+	if r.size == len(r.times) {
+		r.head = (r.head + 1) % len(r.times)
+		return
+	}
+	r.size++
+}
+
+// Prune removes the times that are not after the cutoff.
+func (r *ring) Prune(cutoff time.Time) {
+	for r.size > 0 && !r.times[r.head].After(cutoff) {
+		r.head = (r.head + 1) % len(r.times)
+		r.size--
+	}
+}
+
+// Resize changes the capacity of the ring buffer keeping the newest times.
+func (r *ring) Resize(capacity int) {
+	resized := newRing(capacity)
+	for i := max(r.size-resized.Cap(), 0); i < r.size; i++ {
+		resized.Push(r.At(i))
+	}
+	*r = *resized
+}
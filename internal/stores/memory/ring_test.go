@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGivenFullRingWhenCallingPushThenOldestTimeIsOverwritten(t *testing.T) {
+	now := time.Now()
+	r := newRing(2)
+	r.Push(now)
+	r.Push(now.Add(time.Second))
+	r.Push(now.Add(2 * time.Second))
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, now.Add(time.Second), r.times[r.head])
+}
+
+func TestGivenRingWhenCallingPruneThenTimesNotAfterCutoffAreRemoved(t *testing.T) {
+	now := time.Now()
+	r := newRing(3)
+	r.Push(now)
+	r.Push(now.Add(time.Second))
+	r.Push(now.Add(2 * time.Second))
+	r.Prune(now.Add(time.Second))
+	assert.Equal(t, 1, r.Len())
+	assert.Equal(t, now.Add(2*time.Second), r.times[r.head])
+}
+
+func TestGivenRingWhenCallingResizeThenNewestTimesAreKept(t *testing.T) {
+	now := time.Now()
+	r := newRing(3)
+	r.Push(now)
+	r.Push(now.Add(time.Second))
+	r.Push(now.Add(2 * time.Second))
+
+	r.Resize(2)
+	assert.Equal(t, 2, r.Cap())
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, now.Add(time.Second), r.times[r.head])
+
+	r.Resize(4)
+	assert.Equal(t, 4, r.Cap())
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, now.Add(time.Second), r.times[r.head])
+}
+
+func TestGivenEmptyCapacityWhenCallingPushThenNothingIsAdded(t *testing.T) {
+	r := newRing(0)
+	r.Push(time.Now())
+	assert.Equal(t, 0, r.Len())
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// defaultShards is the number of shards of a memory store, unless
+// configured with WithShards.
+const defaultShards int = 32
+
+// shard represents a share of the keys of a memory store, guarded by its own lock.
+type shard struct {
+	statuses map[string]*status.Status
+	logs     map[string]*ring
+	tats     map[string]time.Time
+	slots    map[string]map[string]time.Time
+	entries  map[string]*list.Element
+	lru      *list.List
+	maxKeys  int
+	mu       sync.Mutex
+}
+
+// newShard returns a new shard holding up to maxKeys keys, or any number if zero.
+func newShard(maxKeys int) *shard {
+	return &shard{
+		statuses: make(map[string]*status.Status),
+		logs:     make(map[string]*ring),
+		tats:     make(map[string]time.Time),
+		slots:    make(map[string]map[string]time.Time),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		maxKeys:  maxKeys,
+	}
+}
+
+// entry represents the usage of a key, ordered by recency in its shard.
+type entry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// touch marks the key as the most recently used, extending its expiration
+// to expiresAt if later, and evicts the least recently used keys past the
+// maximum number of keys.
+//
+// A zero expiresAt keeps the expiration, and only marks keys already stored
+// as used, it must be called with the lock held.
+func (s *shard) touch(key string, expiresAt time.Time) {
+	if element, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(element)
+		if e := element.Value.(*entry); expiresAt.After(e.expiresAt) {
+			e.expiresAt = expiresAt
+		}
+	} else if !expiresAt.IsZero() {
+		s.entries[key] = s.lru.PushFront(&entry{key: key, expiresAt: expiresAt})
+	}
+	for s.maxKeys > 0 && s.lru.Len() > s.maxKeys {
+		s.drop(s.lru.Back().Value.(*entry).key)
+	}
+}
+
+// drop deletes everything stored for a key, it must be called with the lock held.
+func (s *shard) drop(key string) {
+	delete(s.statuses, key)
+	delete(s.logs, key)
+	delete(s.tats, key)
+	delete(s.slots, key)
+	if element, ok := s.entries[key]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// dropExpired drops the keys expired at now and returns how many were dropped.
+func (s *shard) dropExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []string
+	for element := s.lru.Front(); element != nil; element = element.Next() {
+		if e := element.Value.(*entry); !e.expiresAt.After(now) {
+			expired = append(expired, e.key)
+		}
+	}
+	for _, key := range expired {
+		s.drop(key)
+	}
+	return len(expired)
+}
+
+// keys adds the keys with a status, log, TAT or slots stored to the set.
+func (s *shard) keys(keys map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.statuses {
+		keys[key] = true
+	}
+	for key := range s.logs {
+		keys[key] = true
+	}
+	for key := range s.tats {
+		keys[key] = true
+	}
+	for key := range s.slots {
+		keys[key] = true
+	}
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// function shard
+
+func (suite *MemoryStoreTestSuite) TestShardGivenKeyWhenCallShardThenKeyAlwaysMapsToSameShard() {
+	suite.Len(suite.store.shards, defaultShards)
+	used := make(map[*shard]bool)
+	for i := range 1000 {
+		key := "key" + strconv.Itoa(i)
+		suite.Same(suite.store.shard(key), suite.store.shard(key))
+		used[suite.store.shard(key)] = true
+	}
+	suite.Len(used, defaultShards)
+}
+
+func (suite *MemoryStoreTestSuite) TestShardGivenMaxKeysWhenAddingKeysThenEachShardHoldsItsShare() {
+	suite.store = NewMemoryStore(WithMaxKeys(40), WithShards(4))
+	for i := range 1000 {
+		suite.store.Increment(ctx, "key"+strconv.Itoa(i))
+	}
+	for _, sh := range suite.store.shards {
+		suite.Equal(10, sh.lru.Len())
+	}
+	suite.Equal(40, suite.store.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestShardGivenMaxKeysNotDividedByShardsWhenAddingKeysThenCapIsKept() {
+	suite.store = NewMemoryStore(WithMaxKeys(10), WithShards(4))
+	for i := range 1000 {
+		suite.store.Increment(ctx, "key"+strconv.Itoa(i))
+	}
+	suite.Equal(10, suite.store.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestShardGivenMaxKeysBelowShardsWhenAddingKeysThenCapIsKept() {
+	suite.store = NewMemoryStore(WithMaxKeys(5))
+	suite.Len(suite.store.shards, 5)
+	for i := range 1000 {
+		suite.store.Increment(ctx, "key"+strconv.Itoa(i))
+	}
+	suite.Equal(5, suite.store.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestConcurrencyGivenParallelCallsWhenCallingEveryMethodThenCountsAreExact() {
+	suite.store = NewMemoryStore(WithJanitor(time.Millisecond, time.Minute))
+	defer suite.store.Close()
+
+	workers := 16
+	calls := 100
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own := fmt.Sprintf("worker%d", w)
+			for i := range calls {
+				now := time.Now()
+				suite.store.IncrementBy(ctx, "shared", 1)
+				suite.store.Get(ctx, "shared")
+				_, ok, _ := suite.store.Take(ctx, "limited", time.Minute, func(s *status.Status) bool {
+					if s.Count >= calls {
+						return false
+					}
+					s.Count++
+					return true
+				})
+				if ok {
+					allowed.Add(1)
+				}
+				suite.store.Record(ctx, own, now, time.Minute, calls, 1)
+				suite.store.TakeTAT(ctx, own, now, time.Millisecond, time.Hour)
+				slot, _, _ := suite.store.Acquire(ctx, own, 1, time.Minute)
+				suite.store.Release(ctx, own, slot)
+				suite.store.Set(ctx, own+":"+strconv.Itoa(i), status.NewStatus())
+				if i%10 == 0 {
+					suite.store.Keys(ctx, "worker")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := suite.store.Get(ctx, "shared")
+	suite.NoError(err)
+	suite.Equal(workers*calls, s.Count)
+	suite.Equal(int64(calls), allowed.Load())
+	// The shared and limited keys, plus each worker key and the ones it set
+	suite.Equal(2+workers+workers*calls, suite.store.Len())
+}
+
+// benchmarks
+
+// benchmarkTake runs Take in parallel across GOMAXPROCS goroutines on the
+// given number of keys, run it with -cpu 1,2,4,8 to compare the throughput.
+func benchmarkTake(b *testing.B, shards, keys int) {
+	store := NewMemoryStore(WithShards(shards))
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key" + strconv.Itoa(i)
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1))
+		for pb.Next() {
+			store.Take(ctx, names[i%keys], time.Minute, func(s *status.Status) bool {
+				s.Count++
+				return true
+			})
+			i++
+		}
+	})
+}
+
+func BenchmarkTakeSingleLockManyKeys(b *testing.B) {
+	benchmarkTake(b, 1, 10000)
+}
+
+func BenchmarkTakeShardedManyKeys(b *testing.B) {
+	benchmarkTake(b, defaultShards, 10000)
+}
+
+func BenchmarkTakeShardedSingleKey(b *testing.B) {
+	benchmarkTake(b, defaultShards, 1)
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Acquire takes one of the limit slots of a key, which is held until it is
+// released or its lease expires.
+//
+// It returns the slot and true if one was taken.
+func (m *MemoryStore) Acquire(ctx context.Context, key string, limit int, lease time.Duration) (string, bool, error) {
+	// No slot is ever taken, so the key is not stored
+	if limit <= 0 {
+		return "", false, nil
+	}
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := time.Now()
+	slots, ok := sh.slots[key]
+	if !ok {
+		slots = make(map[string]time.Time)
+		sh.slots[key] = slots
+	}
+	for slot, expiresAt := range slots {
+		if !expiresAt.After(now) {
+			delete(slots, slot)
+		}
+	}
+	if len(slots) >= limit {
+		sh.touch(key, time.Time{})
+		return "", false, nil
+	}
+	slot := uuid.New().String()
+	slots[slot] = now.Add(lease)
+	sh.touch(key, now.Add(lease))
+	return slot, true, nil
+}
+
+// Release frees a slot of a key.
+func (m *MemoryStore) Release(ctx context.Context, key string, slot string) error {
+	sh := m.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	slots := sh.slots[key]
+	delete(slots, slot)
+	if len(slots) == 0 {
+		delete(sh.slots, key)
+	}
+	return nil
+}
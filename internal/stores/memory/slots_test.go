@@ -0,0 +1,67 @@
+package memory
+
+import "time"
+
+// function Acquire
+
+func (suite *MemoryStoreTestSuite) TestAcquireGivenSlotsAvailableWhenCallAcquireThenSlotIsTaken() {
+	key := "key"
+	slot, ok, err := suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	suite.NotEmpty(slot)
+	suite.Contains(suite.store.shard(key).slots[key], slot)
+	suite.WithinDuration(time.Now().Add(time.Minute), suite.store.shard(key).slots[key][slot], time.Second)
+}
+
+func (suite *MemoryStoreTestSuite) TestAcquireGivenLimitReachedWhenCallAcquireThenSlotIsNotTaken() {
+	key := "key1"
+	_, ok, err := suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	_, ok, err = suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+
+	_, ok, err = suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.False(ok)
+	suite.Len(suite.store.shard(key).slots[key], 2)
+}
+
+func (suite *MemoryStoreTestSuite) TestAcquireGivenZeroLimitWhenCallAcquireThenSlotIsNotTakenAndKeyIsNotStored() {
+	key := "key1"
+	_, ok, err := suite.store.Acquire(ctx, key, 0, time.Minute)
+	suite.NoError(err)
+	suite.False(ok)
+	suite.NotContains(suite.store.shard(key).slots, key)
+	suite.Zero(suite.store.Len())
+}
+
+func (suite *MemoryStoreTestSuite) TestAcquireGivenExpiredLeaseWhenCallAcquireThenExpiredSlotIsDropped() {
+	key := "key1"
+	suite.store.shard(key).slots[key] = map[string]time.Time{"expired": time.Now().Add(-time.Second)}
+
+	slot, ok, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	suite.NotContains(suite.store.shard(key).slots[key], "expired")
+	suite.Contains(suite.store.shard(key).slots[key], slot)
+}
+
+// function Release
+
+func (suite *MemoryStoreTestSuite) TestReleaseGivenSlotWhenCallReleaseThenSlotIsFreed() {
+	key := "key1"
+	slot, _, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	_, ok, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.False(ok)
+
+	suite.store.Release(ctx, key, slot)
+	suite.NotContains(suite.store.shard(key).slots, key)
+	_, ok, err = suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+}
@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// legacyMigratedKey marks the legacy keys as migrated, so the migration
+// runs once per Redis.
+const legacyMigratedKey = "rate_limiter::legacy_keys_migrated"
+
+// MigrateLegacyKeys moves the keys stored by earlier versions under one of
+// the prefixes to the names read by the rules, replacing the prefix with the
+// one it maps to and wrapping the key in its hash tag, as IP:10.0.0.1 to
+// {ip:10.0.0.1} for the prefix IP: mapped to ip:, the rule named ip. So the
+// counts of the clients carry over as long as the prefixes map to the names
+// of the rules counting them.
+//
+// The statuses in the old string format are stored as hashes and those
+// stored without a TTL, before the TTLs were added, expire after ttl.
+// The logs, arrival times and slots keep their TTL. A key already stored
+// under its new name is kept and the legacy one is deleted. The keys under
+// the prefixes that are not limiter data are left as they are.
+//
+// It runs once per Redis: once every key has been moved it marks the
+// migration as done, and later calls return right away. It is best effort:
+// each key is copied then deleted on its own, as the names may be in
+// different Cluster slots, so a request counted on the legacy key while it
+// is moved is lost. It scans the keys under the prefixes, on every master of
+// a Cluster. It returns how many keys were moved, or an error if Redis could
+// not be reached, in which case the keys left are moved the next time it runs.
+func (r *RedisStore) MigrateLegacyKeys(ctx context.Context, prefixes map[string]string, ttl time.Duration) (int, error) {
+	done, err := r.client.Exists(ctx, legacyMigratedKey).Result()
+	if err != nil || done > 0 {
+		return 0, err
+	}
+	moved := 0
+	for prefix, renamed := range prefixes {
+		var keys []string
+		var mu sync.Mutex
+		err := r.scan(ctx, escapePattern(prefix)+"*", func(redisKey string) {
+			mu.Lock()
+			keys = append(keys, redisKey)
+			mu.Unlock()
+		})
+		if err != nil {
+			return moved, err
+		}
+		for _, key := range keys {
+			ok, err := r.migrateKey(ctx, key, renamed+strings.TrimPrefix(key, prefix), ttl)
+			if err != nil {
+				return moved, err
+			}
+			if ok {
+				moved++
+			}
+		}
+	}
+	return moved, r.client.Set(ctx, legacyMigratedKey, time.Now().Format(time.RFC3339), 0).Err()
+}
+
+// migrateKey moves a legacy key to the tagged name of the renamed key,
+// returning true if it was recognized and moved.
+func (r *RedisStore) migrateKey(ctx context.Context, key, renamed string, ttl time.Duration) (bool, error) {
+	kind, err := r.client.Type(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	// The missing TTLs are negative
+	expiration, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if expiration <= 0 {
+		expiration = ttl
+	}
+	for _, format := range []string{logKeyFormat, tatKeyFormat, slotsKeyFormat} {
+		suffix := strings.TrimPrefix(fmt.Sprintf(format, ""), "{}")
+		if base, ok := strings.CutSuffix(renamed, suffix); ok {
+			return r.moveStructure(ctx, key, fmt.Sprintf(format, base), kind, expiration)
+		}
+	}
+	return r.moveStatus(ctx, key, fmt.Sprintf(statusKeyFormat, renamed), kind, expiration)
+}
+
+// moveStructure moves a legacy log or slots sorted set, or arrival time
+// string, to its tagged name.
+func (r *RedisStore) moveStructure(ctx context.Context, key, tagged, kind string, expiration time.Duration) (bool, error) {
+	var write func(pipe redis.Pipeliner)
+	switch kind {
+	case "zset":
+		members, err := r.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return false, err
+		}
+		write = func(pipe redis.Pipeliner) {
+			for _, member := range members {
+				pipe.ZAdd(ctx, tagged, &member)
+			}
+		}
+	case "string":
+		tat, err := r.client.Get(ctx, key).Int64()
+		if err != nil {
+			// Not an arrival time
+			return false, nil
+		}
+		write = func(pipe redis.Pipeliner) {
+			pipe.Set(ctx, tagged, tat, 0)
+		}
+	default:
+		return false, nil
+	}
+	return r.move(ctx, key, tagged, write, expiration)
+}
+
+// moveStatus moves a legacy status, in the old string format or a hash,
+// to its tagged name as a hash.
+func (r *RedisStore) moveStatus(ctx context.Context, key, tagged, kind string, expiration time.Duration) (bool, error) {
+	var s *status.Status
+	switch kind {
+	case "string":
+		value, err := r.client.Get(ctx, key).Result()
+		if err != nil {
+			return false, err
+		}
+		s = parseLegacyValue(value)
+	case "hash":
+		values, err := r.client.HMGet(ctx, key, countField, startedAtField, previousCountField).Result()
+		if err != nil {
+			return false, err
+		}
+		if _, ok := values[0].(string); ok && values[1] != nil {
+			s = parseFields(values)
+		}
+	}
+	if s == nil {
+		return false, nil
+	}
+	return r.move(ctx, key, tagged, func(pipe redis.Pipeliner) {
+		pipe.HSet(ctx, tagged, statusFields(s)...)
+	}, expiration)
+}
+
+// move writes a legacy key to its tagged name, unless it already exists,
+// with the expiration, then deletes the legacy key.
+func (r *RedisStore) move(ctx context.Context, key, tagged string, write func(pipe redis.Pipeliner), expiration time.Duration) (bool, error) {
+	exists, err := r.client.Exists(ctx, tagged).Result()
+	if err != nil {
+		return false, err
+	}
+	if exists == 0 {
+		_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			write(pipe)
+			if expiration > 0 {
+				pipe.PExpire(ctx, tagged, expiration)
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseLegacyValue returns the status of a value in the old string format,
+// with or without the previous count, or nil if it is not in that format.
+func parseLegacyValue(value string) *status.Status {
+	data := strings.Split(value, "::")
+	if len(data) != 2 && len(data) != 3 {
+		return nil
+	}
+	count, err := strconv.Atoi(data[0])
+	if err != nil {
+		return nil
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, data[1])
+	if err != nil {
+		return nil
+	}
+	s := &status.Status{Count: count, StartedAt: startedAt.Local()}
+	if len(data) == 3 {
+		if s.PreviousCount, err = strconv.Atoi(data[2]); err != nil {
+			return nil
+		}
+	}
+	return s
+}
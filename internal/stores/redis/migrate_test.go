@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// function MigrateLegacyKeys
+
+// legacyPrefixes maps the prefixes of the keys of the first versions to the
+// names of the rules of the default policy.
+var legacyPrefixes = map[string]string{"IP:": "ip:", "API_KEY:": "api_key:"}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenStatusesInOldFormatWhenCallMigrateLegacyKeysThenTheyAreMovedAsHashesUnderTheRuleNames() {
+	startedAt := time.Now().Truncate(time.Second)
+	suite.server.Set("IP:10.0.0.1", fmt.Sprintf("%d::%s", 3, startedAt.Format(time.RFC3339Nano)))
+	suite.server.Set("API_KEY:abc123", fmt.Sprintf("%d::%s::%d", 1, startedAt.Format(time.RFC3339Nano), 4))
+	suite.server.SetTTL("API_KEY:abc123", time.Minute)
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, legacyPrefixes, time.Hour)
+	suite.NoError(err)
+	suite.Equal(2, moved)
+
+	s, err := suite.store.Get(ctx, "ip:10.0.0.1")
+	suite.NoError(err)
+	suite.Equal(&status.Status{Count: 3, StartedAt: startedAt}, s)
+	// The statuses stored without a TTL get one
+	suite.Equal(time.Hour, suite.server.TTL("{ip:10.0.0.1}"))
+	s, err = suite.store.Get(ctx, "api_key:abc123")
+	suite.NoError(err)
+	suite.Equal(&status.Status{Count: 1, StartedAt: startedAt, PreviousCount: 4}, s)
+	suite.Equal(time.Minute, suite.server.TTL("{api_key:abc123}"))
+
+	suite.False(suite.server.Exists("IP:10.0.0.1"))
+	suite.False(suite.server.Exists("API_KEY:abc123"))
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenStatusHashWhenCallMigrateLegacyKeysThenItIsMovedKeepingItsTTL() {
+	startedAt := time.Now().Truncate(time.Microsecond)
+	suite.server.HSet("ip:key", "count", "2", "started_at", fmt.Sprint(startedAt.UnixMicro()), "previous_count", "1")
+	suite.server.SetTTL("ip:key", time.Minute)
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, map[string]string{"ip:": "ip:"}, time.Hour)
+	suite.NoError(err)
+	suite.Equal(1, moved)
+
+	s, err := suite.store.Get(ctx, "ip:key")
+	suite.NoError(err)
+	suite.Equal(&status.Status{Count: 2, StartedAt: startedAt, PreviousCount: 1}, s)
+	suite.Equal(time.Minute, suite.server.TTL("{ip:key}"))
+	suite.False(suite.server.Exists("ip:key"))
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenLogTATAndSlotsWhenCallMigrateLegacyKeysThenTheyAreMovedKeepingTheirTTL() {
+	key := "ip:key"
+	suite.store.client.ZAdd(ctx, key+"::log", &redis.Z{Score: 1, Member: "a"}, &redis.Z{Score: 2, Member: "b"})
+	suite.server.SetTTL(key+"::log", time.Minute)
+	suite.server.Set(key+"::tat", "1700000000000000")
+	suite.server.SetTTL(key+"::tat", time.Second)
+	suite.store.client.ZAdd(ctx, key+"::slots", &redis.Z{Score: 3, Member: "slot"})
+	suite.server.SetTTL(key+"::slots", 2*time.Second)
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, map[string]string{"ip:": "ip:"}, time.Hour)
+	suite.NoError(err)
+	suite.Equal(3, moved)
+
+	members, err := suite.store.client.ZRangeWithScores(ctx, fmt.Sprintf(logKeyFormat, key), 0, -1).Result()
+	suite.NoError(err)
+	suite.Equal([]redis.Z{{Score: 1, Member: "a"}, {Score: 2, Member: "b"}}, members)
+	suite.Equal(time.Minute, suite.server.TTL(fmt.Sprintf(logKeyFormat, key)))
+	tat, err := suite.store.client.Get(ctx, fmt.Sprintf(tatKeyFormat, key)).Int64()
+	suite.NoError(err)
+	suite.Equal(int64(1700000000000000), tat)
+	suite.Equal(time.Second, suite.server.TTL(fmt.Sprintf(tatKeyFormat, key)))
+	suite.Equal(2*time.Second, suite.server.TTL(fmt.Sprintf(slotsKeyFormat, key)))
+	suite.Equal([]string{legacyMigratedKey, fmt.Sprintf(logKeyFormat, key), fmt.Sprintf(slotsKeyFormat, key), fmt.Sprintf(tatKeyFormat, key)}, suite.server.Keys())
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenKeysNotRecognizedWhenCallMigrateLegacyKeysThenTheyAreKept() {
+	suite.server.Set("IP:other", "value")
+	suite.server.Lpush("IP:list::log", "value")
+	suite.server.HSet("IP:hash", "field", "value")
+	// Keys of other applications outside the prefixes are not even read
+	suite.server.Set("other::tat", "1700000000000000")
+	suite.store.client.ZAdd(ctx, "other::log", &redis.Z{Score: 1, Member: "a"})
+	suite.server.Set("other", fmt.Sprintf("%d::%s", 1, time.Now().Format(time.RFC3339Nano)))
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, legacyPrefixes, time.Hour)
+	suite.NoError(err)
+	suite.Zero(moved)
+	suite.Equal([]string{"IP:hash", "IP:list::log", "IP:other", "other", "other::log", "other::tat", legacyMigratedKey}, suite.server.Keys())
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenTaggedKeyExistsWhenCallMigrateLegacyKeysThenItIsKept() {
+	suite.store.IncrementBy(ctx, "ip:key", 5)
+	suite.server.Set("IP:key", fmt.Sprintf("%d::%s", 1, time.Now().Format(time.RFC3339Nano)))
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, legacyPrefixes, time.Hour)
+	suite.NoError(err)
+	suite.Equal(1, moved)
+
+	s, err := suite.store.Get(ctx, "ip:key")
+	suite.NoError(err)
+	suite.Equal(5, s.Count)
+	suite.False(suite.server.Exists("IP:key"))
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenMigrationDoneWhenCallMigrateLegacyKeysAgainThenNothingIsScanned() {
+	moved, err := suite.store.MigrateLegacyKeys(ctx, legacyPrefixes, time.Hour)
+	suite.NoError(err)
+	suite.Zero(moved)
+
+	suite.server.Set("IP:10.0.0.1", fmt.Sprintf("%d::%s", 3, time.Now().Format(time.RFC3339Nano)))
+	moved, err = suite.store.MigrateLegacyKeys(ctx, legacyPrefixes, time.Hour)
+	suite.NoError(err)
+	suite.Zero(moved)
+	suite.True(suite.server.Exists("IP:10.0.0.1"))
+}
+
+func (suite *RedisStoreTestSuite) TestMigrateLegacyKeysGivenPrefixWithGlobCharactersWhenCallMigrateLegacyKeysThenOnlyItsKeysAreMoved() {
+	startedAt := time.Now().Truncate(time.Second)
+	suite.server.Set("a*:key", fmt.Sprintf("%d::%s", 1, startedAt.Format(time.RFC3339Nano)))
+	suite.server.Set("ab:key", fmt.Sprintf("%d::%s", 1, startedAt.Format(time.RFC3339Nano)))
+
+	moved, err := suite.store.MigrateLegacyKeys(ctx, map[string]string{"a*:": "a:"}, time.Hour)
+	suite.NoError(err)
+	suite.Equal(1, moved)
+	suite.True(suite.server.Exists("{a:key}"))
+	suite.True(suite.server.Exists("ab:key"))
+}
@@ -3,91 +3,693 @@ package redis
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const valueFormat string = "%d::%s"
+// maxTakeBackoff is the longest Take waits before retrying when the key is
+// changed by another client during the transaction, it waits a random time
+// up to it so the clients racing for the key do not collide again.
+const maxTakeBackoff time.Duration = time.Millisecond
+
+// The keys of a client share the hash tag in braces, so they are in the
+// same Redis Cluster slot.
+const (
+	statusKeyFormat string = "{%s}"
+	logKeyFormat    string = "{%s}::log"
+	tatKeyFormat    string = "{%s}::tat"
+)
+
+// The fields of the hash holding the status of a key, with the started at
+// time in microseconds.
+const (
+	countField         string = "count"
+	startedAtField     string = "started_at"
+	previousCountField string = "previous_count"
+)
+
+// incrementScript increments the count of the status hash of a key by
+// ARGV[2], starting it at ARGV[1] in microseconds if it does not exist.
+//
+// The TTL of the key is kept, returns the count, started at
+// and previous count fields.
+var incrementScript = redis.NewScript(`
+redis.call("HINCRBY", KEYS[1], "count", ARGV[2])
+redis.call("HSETNX", KEYS[1], "started_at", ARGV[1])
+return redis.call("HMGET", KEYS[1], "count", "started_at", "previous_count")
+`)
+
+// takeWindowScript adds cost to the count of the fixed window in the status
+// hash of a key if it stays within limit, starting a new window at now once
+// the window has passed since it started.
+//
+// The times are in microseconds and the key expires with the window, the
+// started at time is kept as given since Lua numbers lose precision once
+// formatted. Returns {1, count, started at} if the cost was added or
+// {0, count, started at} otherwise.
+var takeWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local fields = redis.call("HMGET", KEYS[1], "count", "started_at")
+local count = tonumber(fields[1]) or 0
+local started = fields[2]
+if not started or now > tonumber(started) + window then
+	count = 0
+	started = ARGV[1]
+end
+if count + cost > limit then
+	return {0, count, started}
+end
+count = count + cost
+redis.call("HSET", KEYS[1], "count", count, "started_at", started, "previous_count", 0)
+redis.call("PEXPIRE", KEYS[1], math.max(math.ceil((tonumber(started) + window - now) / 1000), 1))
+return {1, count, started}
+`)
+
+// takeBucketScript refills the token bucket in the status hash of a key
+// with the whole intervals passed since it started and takes cost tokens
+// from it if at most burst tokens would be taken.
+//
+// The count holds the tokens taken and the started at time the time from
+// which the next refill is measured, keeping the remainder of the interval.
+// The times are in microseconds, formatted as integers since Lua numbers
+// lose precision otherwise, and the key expires once the bucket is full.
+// Returns {1, count, started at} if the tokens were taken or
+// {0, count, started at} with the bucket refilled otherwise.
+var takeBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local fields = redis.call("HMGET", KEYS[1], "count", "started_at")
+local count = tonumber(fields[1]) or 0
+local started = tonumber(fields[2]) or now
+local tokens = 0
+if interval > 0 then
+	tokens = math.max(math.floor((now - started) / interval), 0)
+end
+if interval <= 0 or tokens >= count then
+	count = 0
+	started = now
+else
+	count = count - tokens
+	started = started + tokens * interval
+end
+started = string.format("%.0f", started)
+if count + cost > burst then
+	return {0, count, started}
+end
+count = count + cost
+redis.call("HSET", KEYS[1], "count", count, "started_at", started, "previous_count", 0)
+redis.call("PEXPIRE", KEYS[1], math.max(math.ceil((tonumber(started) + count * interval - now) / 1000), 1))
+return {1, count, started}
+`)
+
+// takeSlidingWindowScript moves the status hash of a key to the fixed window
+// containing now and adds cost to its count if the count plus the previous
+// count, weighted by how much of the previous window still overlaps the
+// rolling window, stays below limit.
+//
+// The times are in microseconds, formatted as integers since Lua numbers
+// lose precision otherwise, and the key expires once both counts have left
+// the rolling window. Returns {1, count, started at, previous count} if the
+// cost was added or {0, count, started at, previous count} with the status
+// moved otherwise.
+var takeSlidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local fields = redis.call("HMGET", KEYS[1], "count", "started_at", "previous_count")
+local count = tonumber(fields[1]) or 0
+local started = tonumber(fields[2]) or now
+local previous = tonumber(fields[3]) or 0
+if window <= 0 then
+	count = 0
+	started = now
+	previous = 0
+else
+	local windows = math.floor((now - started) / window)
+	if windows == 1 then
+		previous = count
+	elseif windows > 1 then
+		previous = 0
+	end
+	if windows > 0 then
+		count = 0
+		started = started + windows * window
+	end
+end
+started = string.format("%.0f", started)
+local weighted = count
+if window > 0 then
+	local elapsed = math.min(math.max(now - tonumber(started), 0), window)
+	weighted = previous * (1 - elapsed / window) + count
+end
+if weighted + cost - 1 >= limit then
+	return {0, count, started, previous}
+end
+count = count + cost
+redis.call("HSET", KEYS[1], "count", count, "started_at", started, "previous_count", previous)
+redis.call("PEXPIRE", KEYS[1], math.max(math.ceil((tonumber(started) + 2 * window - now) / 1000), 1))
+return {1, count, started, previous}
+`)
+
+// scripts are loaded into Redis by LoadScripts.
+var scripts = []*redis.Script{
+	incrementScript,
+	takeWindowScript,
+	takeBucketScript,
+	takeSlidingWindowScript,
+	recordScript,
+	takeTATScript,
+	returnTATScript,
+	acquireScript,
+}
+
+// recordScript adds a request cost times to the sorted set log of a key if at
+// most limit requests would be recorded in the window before it.
+//
+// The scores are the request times in microseconds and the log expires
+// after the window, returns {1, 0, count} if the request was recorded or
+// {0, wait, count} with the microseconds until enough of the oldest requests
+// counted leave the window, along with the number of requests in the window.
+var recordScript = redis.NewScript(`
+local at = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[5])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", at - window)
+local count = redis.call("ZCARD", KEYS[1])
+if limit <= 0 or cost > limit then
+	return {0, window, count}
+end
+local excess = count + cost - limit
+if excess > 0 then
+	local oldest = redis.call("ZRANGE", KEYS[1], excess - 1, excess - 1, "WITHSCORES")
+	return {0, tonumber(oldest[2]) + window - at, count}
+end
+for i = 1, cost do
+	redis.call("ZADD", KEYS[1], at, ARGV[4] .. "-" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], math.max(math.ceil(window / 1000), 1))
+return {1, 0, count + cost}
+`)
+
+// takeTATScript moves the theoretical arrival time (TAT) of a key forward by
+// the interval if it is at most tolerance after now, a TAT in the past or
+// missing is taken as now.
+//
+// The times are in microseconds and the TAT expires once it is in the past,
+// returns {1, tat} if it was moved or {0, tat} otherwise.
+var takeTATScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+if tat - now > tolerance then
+	return {0, tat}
+end
+local new_tat = tat + interval
+redis.call("SET", KEYS[1], new_tat, "PX", math.max(math.ceil((new_tat - now) / 1000), 1))
+return {1, tat}
+`)
+
+// returnTATScript moves the theoretical arrival time (TAT) of a key back by
+// the interval if it is after now, but not before now.
+//
+// The times are in microseconds and the TAT expires once it is in the past.
+var returnTATScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat <= now then
+	return 0
+end
+local new_tat = math.max(tat - interval, now)
+redis.call("SET", KEYS[1], new_tat, "PX", math.max(math.ceil((new_tat - now) / 1000), 1))
+return 1
+`)
 
 // RedisStore represents a memory store for rate limiter statuses.
 type RedisStore struct {
-	client *redis.Client
+	client  redis.UniversalClient
+	tracing *tracingHook
 }
 
-var ctx = context.Background()
-
-// NewRedisStore returns a new Redis store.
+// NewRedisStore returns a new Redis store on a single node.
 func NewRedisStore(address, password string) *RedisStore {
-	client := redis.NewClient(&redis.Options{
+	return NewRedisStoreWithClient(redis.NewClient(&redis.Options{
 		Addr:     address,
 		Password: password,
 		DB:       0,
-	})
-	return &RedisStore{client: client}
+	}))
+}
+
+// NewRedisStoreWithClient returns a new Redis store using the client,
+// which can be a single node, Sentinel or Cluster client.
+func NewRedisStoreWithClient(client redis.UniversalClient) *RedisStore {
+	tracing := &tracingHook{}
+	client.AddHook(tracing)
+	return &RedisStore{client: client, tracing: tracing}
+}
+
+// SetTracerProvider sets the OpenTelemetry tracer provider of the spans
+// started for each Redis call.
+//
+// The calls use the tracer provider of the span in their context by default,
+// as the one of the limiter checking the request, or the global one.
+func (r *RedisStore) SetTracerProvider(provider trace.TracerProvider) {
+	r.tracing.setProvider(provider)
+}
+
+// LoadScripts loads the Lua scripts of the store into Redis, so the first
+// calls do not have to send them.
+//
+// The scripts are called by their SHA1 digest and sent again if Redis does
+// not have them, as after a restart, so loading them is optional.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) LoadScripts(ctx context.Context) error {
+	for _, script := range scripts {
+		if err := script.Load(ctx, r.client).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Get returns the status of a key.
 //
-// If the key does not exist, it resets the status.
-func (r *RedisStore) Get(key string) *status.Status {
-	value, err := r.client.Get(ctx, key).Result()
+// If the key does not exist, it returns a new status without storing it.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Get(ctx context.Context, key string) (*status.Status, error) {
+	return readStatus(ctx, r.client, fmt.Sprintf(statusKeyFormat, key))
+}
+
+// readStatus returns the status stored in a hash, or a new status if it does not exist.
+func readStatus(ctx context.Context, c redis.Cmdable, statusKey string) (*status.Status, error) {
+	values, err := c.HMGet(ctx, statusKey, countField, startedAtField, previousCountField).Result()
 	if err != nil {
-		return r.Reset(key)
+		return nil, err
 	}
-	return parseValue(value)
+	return parseFields(values), nil
 }
 
 // Increment increments the count of a key.
 //
 // If the key does not exist, it resets the status.
-func (r *RedisStore) Increment(key string) *status.Status {
-	s := r.Get(key)
-	s.Count++
-	r.client.Set(
-		ctx,
-		key,
-		fmt.Sprintf(valueFormat, s.Count, s.StartedAt.Format(time.RFC3339)),
-		0,
-	)
-	return r.Get(key)
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Increment(ctx context.Context, key string) (*status.Status, error) {
+	return r.IncrementBy(ctx, key, 1)
 }
 
-// Reset resets the status of a key.
+// IncrementBy atomically increments the count of a key by n, keeping its TTL.
 //
 // If the key does not exist, it creates a new status.
-func (r *RedisStore) Reset(key string) *status.Status {
-	s := status.NewStatus()
-	r.client.Set(
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) IncrementBy(ctx context.Context, key string, n int) (*status.Status, error) {
+	values, err := incrementScript.Run(
 		ctx,
-		key,
-		formatStatus(s),
-		0,
-	)
-	return s
+		r.client,
+		[]string{fmt.Sprintf(statusKeyFormat, key)},
+		time.Now().UnixMicro(),
+		n,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseFields(values), nil
+}
+
+// TakeWindow adds cost to the count of the fixed window of a key if it stays
+// within limit, starting a new window at now once the window has passed
+// since the status started.
+//
+// The decision is taken by a Lua script in a single round trip and the key
+// expires with the window.
+// It returns the status and true if the cost was added,
+// or an error if Redis could not be reached.
+func (r *RedisStore) TakeWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit, cost int) (*status.Status, bool, error) {
+	values, err := takeWindowScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(statusKeyFormat, key)},
+		now.UnixMicro(),
+		window.Microseconds(),
+		limit,
+		cost,
+	).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	allowed, _ := values[0].(int64)
+	return parseFields(values[1:]), allowed == 1, nil
+}
+
+// TakeBucket refills the token bucket of a key with a token per interval
+// passed since the status started and takes cost tokens from it if at most
+// burst tokens would be taken.
+//
+// The decision is taken by a Lua script in a single round trip and the key
+// expires once the bucket is full.
+// It returns the status and true if the tokens were taken,
+// or an error if Redis could not be reached.
+func (r *RedisStore) TakeBucket(ctx context.Context, key string, now time.Time, interval time.Duration, burst, cost int) (*status.Status, bool, error) {
+	values, err := takeBucketScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(statusKeyFormat, key)},
+		now.UnixMicro(),
+		interval.Microseconds(),
+		burst,
+		cost,
+	).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	allowed, _ := values[0].(int64)
+	return parseFields(values[1:]), allowed == 1, nil
+}
+
+// TakeSlidingWindow moves the status of a key to the fixed window containing
+// now and adds cost to its count if the weighted count of the rolling window
+// stays below limit.
+//
+// The decision is taken by a Lua script in a single round trip and the key
+// expires once both counts have left the rolling window.
+// It returns the status and true if the cost was added,
+// or an error if Redis could not be reached.
+func (r *RedisStore) TakeSlidingWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit, cost int) (*status.Status, bool, error) {
+	values, err := takeSlidingWindowScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(statusKeyFormat, key)},
+		now.UnixMicro(),
+		window.Microseconds(),
+		limit,
+		cost,
+	).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	allowed, _ := values[0].(int64)
+	return parseFields(values[1:]), allowed == 1, nil
+}
+
+// Reset resets the status of a key, deleting it along with its log and
+// theoretical arrival time (TAT), as a missing key is a new status.
+//
+// It returns the new status, or an error if Redis could not be reached.
+func (r *RedisStore) Reset(ctx context.Context, key string) (*status.Status, error) {
+	err := r.client.Del(
+		ctx,
+		fmt.Sprintf(statusKeyFormat, key),
+		fmt.Sprintf(logKeyFormat, key),
+		fmt.Sprintf(tatKeyFormat, key),
+	).Err()
+	if err != nil {
+		return nil, err
+	}
+	return status.NewStatus(), nil
+}
+
+// Keys returns the keys stored starting with the prefix, sorted.
+//
+// It scans the whole keyspace, of every master of a Cluster,
+// so it is meant for administration only.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys := make(map[string]bool)
+	var mu sync.Mutex
+	err := r.scan(ctx, "{"+escapePattern(prefix)+"*", func(redisKey string) {
+		if key, ok := clientKey(redisKey); ok {
+			mu.Lock()
+			keys[key] = true
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]string, 0, len(keys))
+	for key := range keys {
+		matched = append(matched, key)
+	}
+	slices.Sort(matched)
+	return matched, nil
+}
+
+// scan calls found with each Redis key matching the pattern, of every master
+// of a Cluster, which are scanned concurrently.
+func (r *RedisStore) scan(ctx context.Context, pattern string, found func(redisKey string)) error {
+	scan := func(ctx context.Context, client *redis.Client) error {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			found(iter.Val())
+		}
+		return iter.Err()
+	}
+	switch client := r.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, scan)
+	case *redis.Client:
+		return scan(ctx, client)
+	default:
+		return fmt.Errorf("unsupported client %T", client)
+	}
 }
 
-func formatStatus(status *status.Status) string {
-	return fmt.Sprintf(valueFormat, status.Count, status.StartedAt.Format(time.RFC3339))
+// clientKey returns the key of a client from one of its Redis keys, which
+// wrap it in a hash tag followed by the suffix of the structure stored.
+func clientKey(redisKey string) (string, bool) {
+	end := strings.LastIndex(redisKey, "}")
+	if !strings.HasPrefix(redisKey, "{") || end < 0 {
+		return "", false
+	}
+	key, suffix := redisKey[1:end], redisKey[end:]
+	for _, format := range []string{statusKeyFormat, logKeyFormat, tatKeyFormat, slotsKeyFormat} {
+		if suffix == strings.TrimPrefix(fmt.Sprintf(format, ""), "{") {
+			return key, true
+		}
+	}
+	return "", false
 }
 
-func parseValue(value string) *status.Status {
-	status := status.NewStatus()
-	data := strings.Split(value, "::")
+// escapePattern escapes the special characters of a Redis glob-style pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
 
-	count, err := strconv.Atoi(data[0])
+// Set sets the status of a key, which is kept until it is reset.
+//
+// If the key does not exist, it creates it with the given status.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Set(ctx context.Context, key string, s *status.Status) (*status.Status, error) {
+	statusKey := fmt.Sprintf(statusKeyFormat, key)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, statusKey)
+		pipe.HSet(ctx, statusKey, statusFields(s)...)
+		return nil
+	})
 	if err != nil {
-		return status
+		return nil, err
 	}
-	status.Count = count
+	return s, nil
+}
 
-	startedAt, err := time.Parse(time.RFC3339, data[1])
+// Take atomically passes the status of a key, or a new status if it does not
+// exist, to the take function, which changes it and returns true to allow
+// the request. The status changed is only stored if it was allowed.
+//
+// The status stored expires after ttl, so Redis drops the idle keys,
+// or keeps the TTL it had if zero.
+//
+// It uses an optimistic transaction watching the key, which is retried
+// if the key is changed by another client before the status is stored,
+// so contention is never reported as an error.
+// It returns the status changed and true if the request was allowed,
+// or an error if Redis could not be reached or the context is done.
+func (r *RedisStore) Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error) {
+	statusKey := fmt.Sprintf(statusKeyFormat, key)
+	var s *status.Status
+	var allowed bool
+	transaction := func(tx *redis.Tx) error {
+		var err error
+		s, err = readStatus(ctx, tx, statusKey)
+		if err != nil {
+			return err
+		}
+		allowed = take(s)
+		if !allowed {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, statusKey, statusFields(s)...)
+			if ttl > 0 {
+				pipe.PExpire(ctx, statusKey, ttl)
+			}
+			return nil
+		})
+		return err
+	}
+	for {
+		err := r.client.Watch(ctx, transaction, statusKey)
+		if err != redis.TxFailedErr {
+			if err != nil {
+				return nil, false, err
+			}
+			return s, allowed, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(rand.N(maxTakeBackoff)):
+		}
+	}
+}
+
+// Record adds the request time cost times to the log of a key if at most
+// limit requests would be recorded in the window before it.
+//
+// The log of each key is a sorted set scored by the request time.
+// It returns true if the request was recorded, otherwise it returns false
+// and how long until enough of the oldest requests counted leave the window,
+// along with the number of requests in the window.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Record(ctx context.Context, key string, at time.Time, window time.Duration, limit, cost int) (bool, int, time.Duration, error) {
+	result, err := recordScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(logKeyFormat, key)},
+		at.UnixMicro(),
+		window.Microseconds(),
+		limit,
+		fmt.Sprintf("%d-%s", at.UnixMicro(), uuid.New().String()),
+		cost,
+	).Int64Slice()
 	if err != nil {
-		return status
+		return false, 0, 0, err
+	}
+	return result[0] == 1, int(result[2]), time.Duration(result[1]) * time.Microsecond, nil
+}
+
+// TakeTAT moves the theoretical arrival time (TAT) of a key forward by the
+// interval if it is at most tolerance after now, a TAT in the past or
+// missing is taken as now.
+//
+// The TAT of each key is stored in microseconds and expires once it is in
+// the past. It returns the TAT before it was moved and true if it was moved,
+// or an error if Redis could not be reached.
+func (r *RedisStore) TakeTAT(ctx context.Context, key string, now time.Time, interval, tolerance time.Duration) (time.Time, bool, error) {
+	result, err := takeTATScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(tatKeyFormat, key)},
+		now.UnixMicro(),
+		interval.Microseconds(),
+		tolerance.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.UnixMicro(result[1]), result[0] == 1, nil
+}
+
+// ReturnTAT moves the theoretical arrival time (TAT) of a key back by the
+// interval if it is after now, but not before now.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) ReturnTAT(ctx context.Context, key string, now time.Time, interval time.Duration) error {
+	return returnTATScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(tatKeyFormat, key)},
+		now.UnixMicro(),
+		interval.Microseconds(),
+	).Err()
+}
+
+// LogCount returns the number of requests in the log of a key in the window
+// before now, without changing it.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) LogCount(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	count, err := r.client.ZCount(
+		ctx,
+		fmt.Sprintf(logKeyFormat, key),
+		fmt.Sprintf("(%d", now.Add(-window).UnixMicro()),
+		"+inf",
+	).Result()
+	return int(count), err
+}
+
+// TAT returns the theoretical arrival time (TAT) of a key, or the zero time
+// if it has none, without changing it.
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) TAT(ctx context.Context, key string) (time.Time, error) {
+	tat, err := r.client.Get(ctx, fmt.Sprintf(tatKeyFormat, key)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMicro(tat), nil
+}
+
+// statusFields returns the fields and values of the hash holding a status.
+func statusFields(s *status.Status) []interface{} {
+	return []interface{}{
+		countField, s.Count,
+		startedAtField, s.StartedAt.UnixMicro(),
+		previousCountField, s.PreviousCount,
+	}
+}
+
+// parseFields returns the status of the count, started at and the optional
+// previous count fields of a hash, or a new status if the hash does not exist.
+func parseFields(values []interface{}) *status.Status {
+	s := status.NewStatus()
+	if len(values) < 2 || values[1] == nil {
+		return s
+	}
+	s.Count = int(parseInt(values[0]))
+	s.StartedAt = time.UnixMicro(parseInt(values[1]))
+	if len(values) > 2 {
+		s.PreviousCount = int(parseInt(values[2]))
+	}
+	return s
+}
+
+// parseInt returns the integer of a value returned by Redis,
+// or zero if it is missing or not an integer.
+func parseInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	default:
+		return 0
 	}
-	status.StartedAt = startedAt
-	return status
 }
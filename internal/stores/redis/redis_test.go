@@ -1,16 +1,23 @@
 package redis
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
 	"github.com/rcbadiale/go-rate-limiter/pkg/status"
 	"github.com/stretchr/testify/suite"
 )
 
+var ctx = context.Background()
+
 type RedisStoreTestSuite struct {
 	suite.Suite
 	server *miniredis.Miniredis
@@ -35,20 +42,19 @@ func TestSuite(t *testing.T) {
 
 // function Get
 
-func (suite *RedisStoreTestSuite) TestGetGivenKeyDoesNotExistsWhenCallGetThenKeyIsCreatedWithDefaultValues() {
+func (suite *RedisStoreTestSuite) TestGetGivenKeyDoesNotExistsWhenCallGetThenReturnsDefaultValuesWithoutStoringThem() {
 	key1 := "key"
 
-	err := suite.store.client.Get(ctx, key1).Err()
+	err := suite.store.client.Get(ctx, fmt.Sprintf(statusKeyFormat, key1)).Err()
 	suite.Equal(redis.Nil.Error(), err.Error())
 
-	status := suite.store.Get(key1)
+	status, err := suite.store.Get(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
 	suite.Equal(0, status.Count)
 	suite.LessOrEqual(time.Since(status.StartedAt), time.Second)
 
-	val, err := suite.store.client.Get(ctx, key1).Result()
-	suite.NoError(err)
-	suite.Equal(fmt.Sprintf(valueFormat, status.Count, status.StartedAt.Format(time.RFC3339)), val)
+	suite.False(suite.server.Exists(fmt.Sprintf(statusKeyFormat, key1)))
 }
 
 func (suite *RedisStoreTestSuite) TestGetGivenKeysWhenCallGetThenReturnsKeyStatus() {
@@ -57,40 +63,49 @@ func (suite *RedisStoreTestSuite) TestGetGivenKeysWhenCallGetThenReturnsKeyStatu
 
 	key1 := "key1"
 	status1 := &status.Status{Count: 1, StartedAt: refTime.Add(-time.Minute)}
-	suite.store.client.Set(
-		ctx,
-		key1,
-		formatStatus(status1),
-		0,
-	)
+	suite.store.Set(ctx, key1, status1)
 
 	key2 := "key2"
 	status2 := &status.Status{Count: 2, StartedAt: refTime.Add(-time.Hour)}
-	suite.store.client.Set(
-		ctx,
-		key2,
-		formatStatus(status2),
-		0,
-	)
-
-	s1 := suite.store.Get(key1)
+	suite.store.Set(ctx, key2, status2)
+
+	s1, err := suite.store.Get(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(s1)
 	suite.Equal(s1, status1)
 
-	s2 := suite.store.Get(key2)
+	s2, err := suite.store.Get(ctx, key2)
+	suite.NoError(err)
 	suite.NotNil(s2)
 	suite.Equal(s2, status2)
 }
 
+// function IncrementBy
+
+func (suite *RedisStoreTestSuite) TestIncrementByGivenKeyWhenCallIncrementByThenCountIncreasesByN() {
+	key1 := "key"
+	s, err := suite.store.IncrementBy(ctx, key1, 5)
+	suite.NoError(err)
+	suite.Equal(5, s.Count)
+
+	s, err = suite.store.IncrementBy(ctx, key1, 3)
+	suite.NoError(err)
+	suite.Equal(8, s.Count)
+	s, err = suite.store.Get(ctx, key1)
+	suite.NoError(err)
+	suite.Equal(8, s.Count)
+}
+
 // // function Increment
 
 func (suite *RedisStoreTestSuite) TestIncrementGivenKeyDoesNotExistsWhenCallIncrementThenKeyIsCreatedWithCountOne() {
 	key1 := "key"
 
-	err := suite.store.client.Get(ctx, key1).Err()
+	err := suite.store.client.Get(ctx, fmt.Sprintf(statusKeyFormat, key1)).Err()
 	suite.Equal(redis.Nil.Error(), err.Error())
 
-	status := suite.store.Increment(key1)
+	status, err := suite.store.Increment(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
 	suite.Equal(1, status.Count)
 	suite.LessOrEqual(time.Since(status.StartedAt), time.Second)
@@ -102,28 +117,20 @@ func (suite *RedisStoreTestSuite) TestIncrementGivenKeysWhenCallIncrementThenCou
 
 	key1 := "key1"
 	status1 := &status.Status{Count: 1, StartedAt: refTime.Add(-time.Minute)}
-	suite.store.client.Set(
-		ctx,
-		key1,
-		formatStatus(status1),
-		0,
-	)
+	suite.store.Set(ctx, key1, status1)
 
 	key2 := "key2"
 	status2 := &status.Status{Count: 2, StartedAt: refTime.Add(-time.Hour)}
-	suite.store.client.Set(
-		ctx,
-		key2,
-		formatStatus(status2),
-		0,
-	)
-
-	s1 := suite.store.Increment(key1)
+	suite.store.Set(ctx, key2, status2)
+
+	s1, err := suite.store.Increment(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(s1)
 	suite.Equal(2, s1.Count)
 	suite.LessOrEqual(s1.StartedAt, refTime.Add(-time.Minute))
 
-	s2 := suite.store.Increment(key2)
+	s2, err := suite.store.Increment(ctx, key2)
+	suite.NoError(err)
 	suite.NotNil(s2)
 	suite.Equal(3, s2.Count)
 	suite.LessOrEqual(s2.StartedAt, refTime.Add(-time.Hour))
@@ -131,16 +138,18 @@ func (suite *RedisStoreTestSuite) TestIncrementGivenKeysWhenCallIncrementThenCou
 
 // function Reset
 
-func (suite *RedisStoreTestSuite) TestResetGivenKeyDoesNotExistsWhenCallResetThenKeyIsCreatedWithDefaultValues() {
+func (suite *RedisStoreTestSuite) TestResetGivenKeyDoesNotExistsWhenCallResetThenReturnsDefaultValues() {
 	key1 := "key"
 
-	err := suite.store.client.Get(ctx, key1).Err()
+	err := suite.store.client.Get(ctx, fmt.Sprintf(statusKeyFormat, key1)).Err()
 	suite.Equal(redis.Nil.Error(), err.Error())
 
-	status := suite.store.Reset(key1)
+	status, err := suite.store.Reset(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status)
 	suite.Equal(0, status.Count)
 	suite.LessOrEqual(time.Since(status.StartedAt), time.Second)
+	suite.False(suite.server.Exists(fmt.Sprintf(statusKeyFormat, key1)))
 }
 
 func (suite *RedisStoreTestSuite) TestResetGivenKeysWhenCallResetThenStatusShouldResetToDefaultValuesAndReturnsKeyStatus() {
@@ -149,20 +158,699 @@ func (suite *RedisStoreTestSuite) TestResetGivenKeysWhenCallResetThenStatusShoul
 
 	key := "key1"
 	status1 := &status.Status{Count: 1, StartedAt: refTime.Add(-time.Hour)}
-	suite.store.client.Set(
-		ctx,
-		key,
-		formatStatus(status1),
-		0,
-	)
-
-	s := suite.store.Get(key)
+	suite.store.Set(ctx, key, status1)
+
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
 	suite.NotNil(s)
 	suite.Equal(1, s.Count)
 	suite.LessOrEqual(s.StartedAt, refTime.Add(-time.Hour))
 
-	s = suite.store.Reset(key)
+	s, err = suite.store.Reset(ctx, key)
+	suite.NoError(err)
 	suite.NotNil(s)
 	suite.Equal(0, s.Count)
 	suite.LessOrEqual(time.Since(s.StartedAt), time.Second)
+	suite.False(suite.server.Exists(fmt.Sprintf(statusKeyFormat, key)))
+}
+
+// function Set
+
+func (suite *RedisStoreTestSuite) TestSetGivenKeyDoesNotExistsWhenCallSetThenKeyIsCreatedWithGivenStatus() {
+	key1 := "key"
+
+	err := suite.store.client.Get(ctx, fmt.Sprintf(statusKeyFormat, key1)).Err()
+	suite.Equal(redis.Nil.Error(), err.Error())
+
+	status1 := &status.Status{Count: 3, StartedAt: time.Now().Add(-time.Minute)}
+	s, err := suite.store.Set(ctx, key1, status1)
+	suite.NoError(err)
+	suite.Equal(status1, s)
+
+	fields, err := suite.store.client.HGetAll(ctx, fmt.Sprintf(statusKeyFormat, key1)).Result()
+	suite.NoError(err)
+	suite.Equal(map[string]string{
+		"count":          "3",
+		"started_at":     fmt.Sprint(status1.StartedAt.UnixMicro()),
+		"previous_count": "0",
+	}, fields)
+}
+
+func (suite *RedisStoreTestSuite) TestSetGivenKeyExistsWhenCallSetThenStatusIsReplacedKeepingSubSecondPrecision() {
+	refTime := time.Now().Truncate(time.Microsecond)
+
+	key := "key1"
+	suite.store.Set(ctx, key, &status.Status{Count: 1, StartedAt: refTime.Add(-time.Hour)})
+	suite.store.Set(ctx, key, &status.Status{Count: 5, StartedAt: refTime.Add(-250 * time.Millisecond)})
+
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.NotNil(s)
+	suite.Equal(5, s.Count)
+	suite.True(refTime.Add(-250 * time.Millisecond).Equal(s.StartedAt))
+}
+
+func (suite *RedisStoreTestSuite) TestResetGivenLogAndTATWhenCallResetThenTheyAreDeleted() {
+	key := "key1"
+	now := time.Now()
+	suite.store.Record(ctx, key, now, time.Minute, 1, 1)
+	suite.store.TakeTAT(ctx, key, now, time.Minute, 0)
+
+	s, err := suite.store.Reset(ctx, key)
+	suite.NoError(err)
+	suite.Equal(0, s.Count)
+	suite.False(suite.server.Exists(fmt.Sprintf(logKeyFormat, key)))
+	suite.False(suite.server.Exists(fmt.Sprintf(tatKeyFormat, key)))
+}
+
+// function Keys
+
+func (suite *RedisStoreTestSuite) TestKeysGivenKeysWhenCallKeysThenReturnKeysWithPrefixSorted() {
+	now := time.Now()
+	suite.store.Increment(ctx, "ip:10.0.0.2")
+	suite.store.Record(ctx, "ip:10.0.0.1", now, time.Minute, 1, 1)
+	suite.store.TakeTAT(ctx, "api_key:abc123", now, time.Minute, 0)
+	suite.store.TakeTAT(ctx, "ip:10.0.0.2", now, time.Minute, 0)
+	suite.store.Increment(ctx, "ip*")
+
+	keys, err := suite.store.Keys(ctx, "ip:")
+	suite.NoError(err)
+	suite.Equal([]string{"ip:10.0.0.1", "ip:10.0.0.2"}, keys)
+
+	keys, err = suite.store.Keys(ctx, "ip*")
+	suite.NoError(err)
+	suite.Equal([]string{"ip*"}, keys)
+}
+
+func (suite *RedisStoreTestSuite) TestKeysGivenKeysWithoutHashTagWhenCallKeysThenTheyAreIgnored() {
+	suite.store.Increment(ctx, "ip:10.0.0.1")
+	suite.server.Set("ip:10.0.0.2", "1::2024-01-01T00:00:00Z::0")
+	suite.server.Set("{ip:10.0.0.3}::other", "1")
+
+	keys, err := suite.store.Keys(ctx, "ip:")
+	suite.NoError(err)
+	suite.Equal([]string{"ip:10.0.0.1"}, keys)
+}
+
+// Redis Cluster
+
+func (suite *RedisStoreTestSuite) TestGivenKeyWhenFormattingItsRedisKeysThenTheyShareTheHashTag() {
+	key := "api_key:{abc}"
+	for _, format := range []string{statusKeyFormat, logKeyFormat, tatKeyFormat, slotsKeyFormat} {
+		redisKey := fmt.Sprintf(format, key)
+		// The hash tag is what is between the first braces
+		start := strings.Index(redisKey, "{")
+		end := strings.Index(redisKey[start:], "}") + start
+		suite.Equal("api_key:{abc", redisKey[start+1:end])
+
+		clientKey, ok := clientKey(redisKey)
+		suite.True(ok)
+		suite.Equal(key, clientKey)
+	}
+}
+
+func (suite *RedisStoreTestSuite) TestGivenClusterClientWhenCallingStoreThenKeysOfAClientAreHandledTogether() {
+	store := NewRedisStoreWithClient(redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{suite.server.Addr()},
+	}))
+	key := "ip:10.0.0.1"
+	now := time.Now()
+	_, allowed, err := store.TakeWindow(ctx, key, now, time.Minute, 5, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	_, _, _, err = store.Record(ctx, key, now, time.Minute, 5, 1)
+	suite.NoError(err)
+	_, _, err = store.TakeTAT(ctx, key, now, time.Second, 0)
+	suite.NoError(err)
+
+	keys, err := store.Keys(ctx, "ip:")
+	suite.NoError(err)
+	suite.Equal([]string{key}, keys)
+
+	_, err = store.Reset(ctx, key)
+	suite.NoError(err)
+	keys, err = store.Keys(ctx, "")
+	suite.NoError(err)
+	suite.Empty(keys)
+}
+
+// function Record
+
+func (suite *RedisStoreTestSuite) TestRecordGivenKeyDoesNotExistsWhenCallRecordThenLogIsCreatedWithExpiration() {
+	key1 := "key"
+	recorded, count, wait, err := suite.store.Record(ctx, key1, time.Now(), time.Minute, 3, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	suite.Equal(1, count)
+	suite.Zero(wait)
+
+	logKey := fmt.Sprintf(logKeyFormat, key1)
+	card, err := suite.store.client.ZCard(ctx, logKey).Result()
+	suite.NoError(err)
+	suite.Equal(int64(1), card)
+	suite.Equal(time.Minute, suite.server.TTL(logKey))
+}
+
+func (suite *RedisStoreTestSuite) TestRecordGivenZeroWindowWhenCallRecordThenLogExpiresAfterOneMillisecond() {
+	key1 := "key"
+	recorded, _, _, err := suite.store.Record(ctx, key1, time.Now(), 0, 3, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+
+	logKey := fmt.Sprintf(logKeyFormat, key1)
+	suite.True(suite.server.Exists(logKey))
+	suite.Equal(time.Millisecond, suite.server.TTL(logKey))
+}
+
+func (suite *RedisStoreTestSuite) TestRecordGivenLimitReachedInWindowWhenCallRecordThenRequestIsNotRecorded() {
+	key := "key1"
+	now := time.Now()
+	recorded, _, _, err := suite.store.Record(ctx, key, now.Add(-30*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(-10*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, count, wait, err := suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(2, count)
+	suite.Equal(30*time.Second, wait)
+
+	// The first request leaves the window after 30 seconds
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(30*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, wait, err = suite.store.Record(ctx, key, now.Add(49*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(time.Second, wait)
+	recorded, _, _, err = suite.store.Record(ctx, key, now.Add(50*time.Second), time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+}
+
+func (suite *RedisStoreTestSuite) TestRecordGivenSameTimeWhenCallRecordThenEachRequestIsRecorded() {
+	key := "key1"
+	now := time.Now()
+	recorded, _, _, err := suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, _, err = suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.True(recorded)
+	recorded, _, _, err = suite.store.Record(ctx, key, now, time.Minute, 2, 1)
+	suite.NoError(err)
+	suite.False(recorded)
+}
+
+func (suite *RedisStoreTestSuite) TestRecordGivenCostWhenCallRecordThenRequestIsRecordedCostTimes() {
+	key := "key1"
+	now := time.Now()
+	recorded, count, _, err := suite.store.Record(ctx, key, now.Add(-30*time.Second), time.Minute, 5, 3)
+	suite.NoError(err)
+	suite.True(recorded)
+	suite.Equal(3, count)
+
+	// The three requests must leave the window for another cost of three
+	recorded, count, wait, err := suite.store.Record(ctx, key, now, time.Minute, 5, 3)
+	suite.NoError(err)
+	suite.False(recorded)
+	suite.Equal(3, count)
+	suite.Equal(30*time.Second, wait)
+
+	recorded, _, _, err = suite.store.Record(ctx, key, now, time.Minute, 5, 6)
+	suite.NoError(err)
+	suite.False(recorded)
+}
+
+// function TakeWindow
+
+func (suite *RedisStoreTestSuite) TestTakeWindowGivenKeyDoesNotExistsWhenCallTakeWindowThenWindowStartsNowAndExpiresWithIt() {
+	key := "key"
+	now := time.Now().Truncate(time.Microsecond)
+	s, allowed, err := suite.store.TakeWindow(ctx, key, now, time.Minute, 5, 2)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(2, s.Count)
+	suite.True(now.Equal(s.StartedAt))
+	suite.Equal(time.Minute, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+
+	stored, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(s, stored)
+}
+
+func (suite *RedisStoreTestSuite) TestTakeWindowGivenLimitReachedWhenCallTakeWindowThenCostIsNotAdded() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeWindow(ctx, key, now, time.Minute, 5, 4)
+
+	s, allowed, err := suite.store.TakeWindow(ctx, key, now.Add(time.Second), time.Minute, 5, 2)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(4, s.Count)
+	suite.True(now.Equal(s.StartedAt))
+
+	s, allowed, err = suite.store.TakeWindow(ctx, key, now.Add(time.Second), time.Minute, 5, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(5, s.Count)
+	// The key expires with the window, not after a whole window from now
+	suite.Equal(59*time.Second, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeWindowGivenWindowPassedWhenCallTakeWindowThenNewWindowStarts() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeWindow(ctx, key, now, time.Minute, 5, 5)
+
+	later := now.Add(time.Minute + time.Microsecond)
+	s, allowed, err := suite.store.TakeWindow(ctx, key, later, time.Minute, 5, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(1, s.Count)
+	suite.True(later.Equal(s.StartedAt))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeWindowGivenParallelClientsWhenCallTakeWindowThenOnlyLimitIsAllowed() {
+	key := "key1"
+	limit := 10
+	var allowedCount atomic.Int64
+	var wg sync.WaitGroup
+	for range 5 {
+		store := NewRedisStore(suite.server.Addr(), "")
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, allowed, err := store.TakeWindow(ctx, key, time.Now(), time.Minute, limit, 1)
+				suite.NoError(err)
+				if allowed {
+					allowedCount.Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	suite.Equal(int64(limit), allowedCount.Load())
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(limit, s.Count)
+}
+
+// function TakeBucket
+
+func (suite *RedisStoreTestSuite) TestTakeBucketGivenKeyDoesNotExistsWhenCallTakeBucketThenTokensAreTakenUntilBucketIsFull() {
+	key := "key"
+	now := time.Now().Truncate(time.Microsecond)
+	s, allowed, err := suite.store.TakeBucket(ctx, key, now, time.Second, 3, 2)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(2, s.Count)
+	suite.True(now.Equal(s.StartedAt))
+	suite.Equal(2*time.Second, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+
+	s, allowed, err = suite.store.TakeBucket(ctx, key, now, time.Second, 3, 2)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(2, s.Count)
+}
+
+func (suite *RedisStoreTestSuite) TestTakeBucketGivenIntervalsPassedWhenCallTakeBucketThenWholeTokensAreRefilled() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeBucket(ctx, key, now, time.Second, 3, 3)
+
+	// The remainder of the interval is kept for the next refill
+	s, allowed, err := suite.store.TakeBucket(ctx, key, now.Add(1500*time.Millisecond), time.Second, 3, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(3, s.Count)
+	suite.True(now.Add(time.Second).Equal(s.StartedAt))
+	suite.Equal(2500*time.Millisecond, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+
+	s, allowed, err = suite.store.TakeBucket(ctx, key, now.Add(10*time.Second), time.Second, 3, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(1, s.Count)
+	suite.True(now.Add(10 * time.Second).Equal(s.StartedAt))
+}
+
+// function TakeSlidingWindow
+
+func (suite *RedisStoreTestSuite) TestTakeSlidingWindowGivenKeyDoesNotExistsWhenCallTakeSlidingWindowThenCountIsAddedUntilLimit() {
+	key := "key"
+	now := time.Now().Truncate(time.Microsecond)
+	s, allowed, err := suite.store.TakeSlidingWindow(ctx, key, now, time.Minute, 5, 4)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(4, s.Count)
+	suite.True(now.Equal(s.StartedAt))
+	suite.Equal(2*time.Minute, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+
+	s, allowed, err = suite.store.TakeSlidingWindow(ctx, key, now, time.Minute, 5, 2)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(4, s.Count)
+}
+
+func (suite *RedisStoreTestSuite) TestTakeSlidingWindowGivenNextWindowWhenCallTakeSlidingWindowThenPreviousCountIsWeighted() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeSlidingWindow(ctx, key, now, time.Minute, 5, 4)
+
+	// A quarter of the previous window left the rolling window, so it weights 3
+	later := now.Add(time.Minute + 15*time.Second)
+	s, allowed, err := suite.store.TakeSlidingWindow(ctx, key, later, time.Minute, 5, 2)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(&status.Status{Count: 2, StartedAt: now.Add(time.Minute), PreviousCount: 4}, s)
+
+	s, allowed, err = suite.store.TakeSlidingWindow(ctx, key, later, time.Minute, 5, 1)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(2, s.Count)
+	suite.Equal(4, s.PreviousCount)
+
+	s, allowed, err = suite.store.TakeSlidingWindow(ctx, key, now.Add(5*time.Minute), time.Minute, 5, 1)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(&status.Status{Count: 1, StartedAt: now.Add(5 * time.Minute)}, s)
+}
+
+// Limiters
+
+func (suite *RedisStoreTestSuite) TestGivenParallelClientsWhenLimitersDecideOnOneKeyThenExactlyLimitIsAllowedWithoutErrors() {
+	limit := 10
+	for name, newLimiter := range map[string]func(store *RedisStore) *limiter.Limiter{
+		"fixed window": func(store *RedisStore) *limiter.Limiter {
+			return limiter.NewLimiter(store, limit, time.Minute)
+		},
+		"token bucket": func(store *RedisStore) *limiter.Limiter {
+			return limiter.NewTokenBucketLimiter(store, 1, time.Minute, limit)
+		},
+		"sliding window": func(store *RedisStore) *limiter.Limiter {
+			return limiter.NewSlidingWindowLimiter(store, limit, time.Minute)
+		},
+		"sliding log": func(store *RedisStore) *limiter.Limiter {
+			return limiter.NewSlidingLogLimiter(store, limit, time.Minute)
+		},
+		"gcra": func(store *RedisStore) *limiter.Limiter {
+			return limiter.NewGCRALimiter(store, 1, time.Minute, limit)
+		},
+	} {
+		var allowedCount, errorCount atomic.Int64
+		var wg sync.WaitGroup
+		for range 8 {
+			l := newLimiter(NewRedisStore(suite.server.Addr(), ""))
+			for range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 20 {
+						allowed, _, err := l.Allow(ctx, name)
+						if err != nil {
+							errorCount.Add(1)
+						} else if allowed {
+							allowedCount.Add(1)
+						}
+					}
+				}()
+			}
+		}
+		wg.Wait()
+
+		suite.Zero(errorCount.Load(), name)
+		suite.Equal(int64(limit), allowedCount.Load(), name)
+	}
+}
+
+func (suite *RedisStoreTestSuite) TestIncrementByGivenParallelClientsWhenCallIncrementByThenNoIncrementIsLost() {
+	key := "key1"
+	var wg sync.WaitGroup
+	for range 5 {
+		store := NewRedisStore(suite.server.Addr(), "")
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.IncrementBy(ctx, key, 2)
+				suite.NoError(err)
+			}()
+		}
+	}
+	wg.Wait()
+
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(100, s.Count)
+}
+
+// function LoadScripts
+
+func (suite *RedisStoreTestSuite) TestLoadScriptsWhenCallLoadScriptsThenScriptsAreCalledBySHA() {
+	suite.NoError(suite.store.LoadScripts(ctx))
+	for _, script := range scripts {
+		exists, err := suite.store.client.ScriptExists(ctx, script.Hash()).Result()
+		suite.NoError(err)
+		suite.Equal([]bool{true}, exists)
+	}
+
+	// The scripts are sent again once Redis loses them
+	suite.NoError(suite.store.client.ScriptFlush(ctx).Err())
+	_, err := suite.store.IncrementBy(ctx, "key", 1)
+	suite.NoError(err)
+}
+
+// functions LogCount and TAT
+
+func (suite *RedisStoreTestSuite) TestLogCountGivenLogWhenCallLogCountThenReturnsRequestsInWindowWithoutChangingIt() {
+	now := time.Now()
+	suite.store.Record(ctx, "key", now.Add(-2*time.Second), time.Minute, 5, 1)
+	suite.store.Record(ctx, "key", now.Add(-time.Second), time.Minute, 5, 2)
+
+	count, err := suite.store.LogCount(ctx, "key", now, 1500*time.Millisecond)
+	suite.NoError(err)
+	suite.Equal(2, count)
+	count, err = suite.store.LogCount(ctx, "key", now, time.Minute)
+	suite.NoError(err)
+	suite.Equal(3, count)
+	count, err = suite.store.LogCount(ctx, "missing", now, time.Minute)
+	suite.NoError(err)
+	suite.Zero(count)
+}
+
+func (suite *RedisStoreTestSuite) TestTATGivenTATWhenCallTATThenReturnsItWithoutChangingIt() {
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeTAT(ctx, "key", now, time.Second, 0)
+
+	tat, err := suite.store.TAT(ctx, "key")
+	suite.NoError(err)
+	suite.True(now.Add(time.Second).Equal(tat))
+	tat, err = suite.store.TAT(ctx, "key")
+	suite.NoError(err)
+	suite.True(now.Add(time.Second).Equal(tat))
+	tat, err = suite.store.TAT(ctx, "missing")
+	suite.NoError(err)
+	suite.True(tat.IsZero())
+}
+
+// function TakeTAT
+
+func (suite *RedisStoreTestSuite) TestTakeTATGivenKeyDoesNotExistsWhenCallTakeTATThenTATIsNowAndMovedWithExpiration() {
+	key := "key"
+	now := time.Now().Truncate(time.Microsecond)
+	tat, taken, err := suite.store.TakeTAT(ctx, key, now, time.Second, 0)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.True(now.Equal(tat))
+
+	tatKey := fmt.Sprintf(tatKeyFormat, key)
+	val, err := suite.store.client.Get(ctx, tatKey).Int64()
+	suite.NoError(err)
+	suite.Equal(now.Add(time.Second).UnixMicro(), val)
+	suite.Equal(time.Second, suite.server.TTL(tatKey))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeTATGivenTATAfterToleranceWhenCallTakeTATThenTATIsNotMoved() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	tat, taken, err := suite.store.TakeTAT(ctx, key, now, time.Second, 2*time.Second)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.True(now.Equal(tat))
+	suite.store.TakeTAT(ctx, key, now, time.Second, 2*time.Second)
+	suite.store.TakeTAT(ctx, key, now, time.Second, 2*time.Second)
+
+	tat, taken, err = suite.store.TakeTAT(ctx, key, now, time.Second, 2*time.Second)
+	suite.NoError(err)
+	suite.False(taken)
+	suite.True(now.Add(3 * time.Second).Equal(tat))
+
+	tat, taken, err = suite.store.TakeTAT(ctx, key, now.Add(time.Second), time.Second, 2*time.Second)
+	suite.NoError(err)
+	suite.True(taken)
+	suite.True(now.Add(3 * time.Second).Equal(tat))
+}
+
+func (suite *RedisStoreTestSuite) TestReturnTATGivenTATAfterNowWhenCallReturnTATThenTATIsMovedBackNotBeforeNow() {
+	key := "key1"
+	now := time.Now().Truncate(time.Microsecond)
+	suite.store.TakeTAT(ctx, key, now, 3*time.Second, 0)
+	tatKey := fmt.Sprintf(tatKeyFormat, key)
+
+	suite.NoError(suite.store.ReturnTAT(ctx, key, now, time.Second))
+	val, err := suite.store.client.Get(ctx, tatKey).Int64()
+	suite.NoError(err)
+	suite.Equal(now.Add(2*time.Second).UnixMicro(), val)
+	suite.Equal(2*time.Second, suite.server.TTL(tatKey))
+
+	suite.NoError(suite.store.ReturnTAT(ctx, key, now, 5*time.Second))
+	val, err = suite.store.client.Get(ctx, tatKey).Int64()
+	suite.NoError(err)
+	suite.Equal(now.UnixMicro(), val)
+}
+
+func (suite *RedisStoreTestSuite) TestReturnTATGivenKeyDoesNotExistsWhenCallReturnTATThenNothingIsStored() {
+	suite.NoError(suite.store.ReturnTAT(ctx, "key", time.Now(), time.Second))
+	suite.Empty(suite.server.Keys())
+}
+
+// Redis errors
+
+func (suite *RedisStoreTestSuite) TestGetGivenRedisErrorWhenCallGetThenReturnsErrorWithoutResettingStatus() {
+	key := "key1"
+	_, err := suite.store.Set(ctx, key, &status.Status{Count: 5, StartedAt: time.Now()})
+	suite.NoError(err)
+
+	suite.server.SetError("unavailable")
+	s, err := suite.store.Get(ctx, key)
+	suite.Error(err)
+	suite.Nil(s)
+	_, err = suite.store.Increment(ctx, key)
+	suite.Error(err)
+	_, _, _, err = suite.store.Record(ctx, key, time.Now(), time.Minute, 1, 1)
+	suite.Error(err)
+	_, _, err = suite.store.TakeTAT(ctx, key, time.Now(), time.Second, 0)
+	suite.Error(err)
+
+	suite.server.SetError("")
+	s, err = suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(5, s.Count)
+}
+
+// function Take
+
+func (suite *RedisStoreTestSuite) TestTakeGivenKeyDoesNotExistsWhenCallTakeThenNewStatusIsTaken() {
+	key1 := "key"
+	s, allowed, err := suite.store.Take(ctx, key1, time.Minute, func(s *status.Status) bool {
+		suite.Equal(0, s.Count)
+		s.Count++
+		return true
+	})
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(1, s.Count)
+
+	stored, err := suite.store.Get(ctx, key1)
+	suite.NoError(err)
+	suite.Equal(1, stored.Count)
+	suite.True(s.StartedAt.Truncate(time.Microsecond).Equal(stored.StartedAt))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeGivenTTLWhenCallTakeThenKeyExpiresAfterIt() {
+	key := "key"
+	_, _, err := suite.store.Take(ctx, key, 2*time.Second, func(s *status.Status) bool {
+		s.Count++
+		return true
+	})
+	suite.NoError(err)
+	suite.Equal(2*time.Second, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+
+	suite.server.FastForward(2 * time.Second)
+	suite.False(suite.server.Exists(fmt.Sprintf(statusKeyFormat, key)))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeGivenZeroTTLWhenCallTakeThenKeyKeepsItsTTL() {
+	key := "key"
+	_, _, err := suite.store.Take(ctx, key, time.Minute, func(s *status.Status) bool {
+		s.Count++
+		return true
+	})
+	suite.NoError(err)
+
+	s, err := suite.store.IncrementBy(ctx, key, 2)
+	suite.NoError(err)
+	suite.Equal(3, s.Count)
+	suite.Equal(time.Minute, suite.server.TTL(fmt.Sprintf(statusKeyFormat, key)))
+}
+
+func (suite *RedisStoreTestSuite) TestTakeGivenRequestNotAllowedWhenCallTakeThenStatusIsNotStored() {
+	key := "key1"
+	status1 := &status.Status{Count: 1, StartedAt: time.Now().Truncate(time.Second)}
+	suite.store.Set(ctx, key, status1)
+
+	s, allowed, err := suite.store.Take(ctx, key, time.Minute, func(s *status.Status) bool {
+		s.Count = 10
+		return false
+	})
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(10, s.Count)
+
+	s, err = suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(status1, s)
+}
+
+func (suite *RedisStoreTestSuite) TestTakeGivenContextDoneWhileRetryingWhenCallTakeThenReturnsContextError() {
+	key := "key1"
+	canceled, cancel := context.WithCancel(ctx)
+	_, _, err := suite.store.Take(canceled, key, time.Minute, func(s *status.Status) bool {
+		// Changing the key fails the transaction until the context is done
+		suite.store.IncrementBy(ctx, key, 1)
+		if s.Count >= 3 {
+			cancel()
+		}
+		s.Count++
+		return true
+	})
+	suite.ErrorIs(err, context.Canceled)
+}
+
+func (suite *RedisStoreTestSuite) TestTakeGivenParallelClientsWhenCallTakeThenOnlyLimitIsAllowed() {
+	key := "key1"
+	limit := 10
+	var allowedCount atomic.Int64
+	var wg sync.WaitGroup
+	for range 8 {
+		store := NewRedisStore(suite.server.Addr(), "")
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, allowed, err := store.Take(ctx, key, time.Minute, func(s *status.Status) bool {
+					if s.ReachedLimit(limit) {
+						return false
+					}
+					s.Count++
+					return true
+				})
+				// Contention is retried, never reported as an error
+				suite.NoError(err)
+				if allowed {
+					allowedCount.Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	s, err := suite.store.Get(ctx, key)
+	suite.NoError(err)
+	suite.Equal(limit, s.Count)
+	suite.Equal(int64(limit), allowedCount.Load())
 }
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const slotsKeyFormat string = "{%s}::slots"
+
+// acquireScript takes one of the limit slots of a key if less than limit
+// slots are held, dropping the slots whose lease has expired.
+//
+// The slots are a sorted set scored by their lease expiration in
+// milliseconds and the set expires with the newest lease,
+// returns 1 if the slot was taken or 0 otherwise.
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= limit then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + lease, ARGV[4])
+redis.call("PEXPIRE", KEYS[1], lease)
+return 1
+`)
+
+// Acquire takes one of the limit slots of a key, which is held until it is
+// released or its lease expires.
+//
+// The slots of each key are a sorted set scored by their lease expiration,
+// so slots of crashed instances are dropped once expired.
+// It returns the slot and true if one was taken,
+// or an error if Redis could not be reached.
+func (r *RedisStore) Acquire(ctx context.Context, key string, limit int, lease time.Duration) (string, bool, error) {
+	slot := uuid.New().String()
+	acquired, err := acquireScript.Run(
+		ctx,
+		r.client,
+		[]string{fmt.Sprintf(slotsKeyFormat, key)},
+		time.Now().UnixMilli(),
+		max(lease.Milliseconds(), 1),
+		limit,
+		slot,
+	).Int()
+	if err != nil {
+		return "", false, err
+	}
+	return slot, acquired == 1, nil
+}
+
+// Release frees a slot of a key.
+//
+// It returns an error if Redis could not be reached.
+func (r *RedisStore) Release(ctx context.Context, key string, slot string) error {
+	return r.client.ZRem(ctx, fmt.Sprintf(slotsKeyFormat, key), slot).Err()
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// function Acquire
+
+func (suite *RedisStoreTestSuite) TestAcquireGivenSlotsAvailableWhenCallAcquireThenSlotIsTakenWithLease() {
+	key := "key"
+	slot, ok, err := suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	suite.NotEmpty(slot)
+
+	slotsKey := fmt.Sprintf(slotsKeyFormat, key)
+	score, err := suite.store.client.ZScore(ctx, slotsKey, slot).Result()
+	suite.NoError(err)
+	suite.InDelta(time.Now().Add(time.Minute).UnixMilli(), score, 1000)
+	suite.Equal(time.Minute, suite.server.TTL(slotsKey))
+}
+
+func (suite *RedisStoreTestSuite) TestAcquireGivenLimitReachedWhenCallAcquireThenSlotIsNotTaken() {
+	key := "key1"
+	_, ok, err := suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	_, ok, err = suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+
+	_, ok, err = suite.store.Acquire(ctx, key, 2, time.Minute)
+	suite.NoError(err)
+	suite.False(ok)
+}
+
+func (suite *RedisStoreTestSuite) TestAcquireGivenExpiredLeaseWhenCallAcquireThenExpiredSlotIsDropped() {
+	key := "key1"
+	slotsKey := fmt.Sprintf(slotsKeyFormat, key)
+	suite.store.client.ZAdd(ctx, slotsKey, &redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: "expired"})
+
+	_, ok, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+	err = suite.store.client.ZScore(ctx, slotsKey, "expired").Err()
+	suite.Equal(redis.Nil, err)
+}
+
+// function Release
+
+func (suite *RedisStoreTestSuite) TestReleaseGivenSlotWhenCallReleaseThenSlotIsFreed() {
+	key := "key1"
+	slot, _, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	_, ok, err := suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.False(ok)
+
+	suite.store.Release(ctx, key, slot)
+	_, ok, err = suite.store.Acquire(ctx, key, 1, time.Minute)
+	suite.NoError(err)
+	suite.True(ok)
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the spans of the Redis calls.
+const tracerName = "github.com/rcbadiale/go-rate-limiter/internal/stores/redis"
+
+// tracingHook is a Redis hook starting a client span for each command or
+// pipeline, as a child of the span in the context of the call.
+//
+// It uses the tracer provider set on the store, if any, otherwise the one of
+// the span in the context, as the ones of the limiters, or the global one.
+type tracingHook struct {
+	provider trace.TracerProvider
+	mu       sync.RWMutex
+}
+
+// setProvider sets the tracer provider of the spans, nil to use the one of
+// the span in the context.
+func (h *tracingHook) setProvider(provider trace.TracerProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.provider = provider
+}
+
+// tracer returns the tracer of the spans of the Redis calls of the context.
+func (h *tracingHook) tracer(ctx context.Context) trace.Tracer {
+	h.mu.RLock()
+	provider := h.provider
+	h.mu.RUnlock()
+	if provider == nil {
+		if parent := trace.SpanFromContext(ctx); parent.SpanContext().IsValid() {
+			provider = parent.TracerProvider()
+		} else {
+			provider = otel.GetTracerProvider()
+		}
+	}
+	return provider.Tracer(tracerName)
+}
+
+func (h *tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = h.startSpan(ctx, "redis."+cmd.Name(), cmd.Name())
+	return ctx, nil
+}
+
+func (*tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endSpan(trace.SpanFromContext(ctx), cmd.Err())
+	return nil
+}
+
+func (h *tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	ctx, _ = h.startSpan(ctx, "redis.pipeline", strings.Join(names, " "))
+	return ctx, nil
+}
+
+func (*tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	endSpan(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// startSpan starts a client span for a Redis call with the operations called.
+func (h *tracingHook) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return h.tracer(ctx).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", operation),
+		),
+	)
+}
+
+// endSpan ends the span of a Redis call, recording the error if it failed.
+//
+// A missing key is not taken as an error, nor a missing script, which is
+// loaded right after by the scripts run.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != redis.Nil && !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing
+
+func (suite *RedisStoreTestSuite) TestGivenSpanInContextWhenCallingStoreThenRedisCallsAreChildSpans() {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	parentCtx, parent := otel.Tracer("test").Start(ctx, "request")
+	_, _, err := suite.store.TakeTAT(parentCtx, "key", time.Now(), time.Second, 0)
+	suite.NoError(err)
+	_, _, err = suite.store.Take(parentCtx, "key", time.Minute, func(s *status.Status) bool {
+		s.Count++
+		return true
+	})
+	suite.NoError(err)
+	parent.End()
+
+	// The spans of the Redis calls end before the one of the request
+	spans := recorder.Ended()
+	suite.Require().Greater(len(spans), 2)
+	suite.Equal("request", spans[len(spans)-1].Name())
+	calls := spans[:len(spans)-1]
+	for _, span := range calls {
+		suite.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+		suite.Equal(trace.SpanKindClient, span.SpanKind())
+		suite.Contains(span.Attributes(), attribute.String("db.system", "redis"))
+	}
+	operations := make(map[string]attribute.KeyValue)
+	for _, span := range calls {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "db.operation" {
+				operations[span.Name()] = attr
+			}
+		}
+	}
+	suite.Contains(operations, "redis.eval")
+	suite.Equal(attribute.String("db.operation", "multi hset pexpire exec"), operations["redis.pipeline"])
+}
+
+func (suite *RedisStoreTestSuite) TestGivenLimiterTracerProviderWhenCallingStoreThenRedisCallsUseIt() {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	l := limiter.NewLimiter(suite.store, 1, time.Minute)
+	l.SetTracerProvider(provider)
+
+	_, _, err := l.Allow(ctx, "key")
+	suite.NoError(err)
+
+	spans := recorder.Ended()
+	suite.Require().Greater(len(spans), 1)
+	check := spans[len(spans)-1]
+	suite.Equal("rate_limiter.check", check.Name())
+	for _, span := range spans[:len(spans)-1] {
+		suite.Equal(check.SpanContext().SpanID(), span.Parent().SpanID())
+		suite.Contains(span.Attributes(), attribute.String("db.system", "redis"))
+	}
+}
+
+func (suite *RedisStoreTestSuite) TestGivenStoreTracerProviderWhenCallingStoreThenRedisCallsUseIt() {
+	recorder := tracetest.NewSpanRecorder()
+	suite.store.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer suite.store.SetTracerProvider(nil)
+
+	_, _, err := suite.store.TakeTAT(ctx, "key", time.Now(), time.Second, 0)
+	suite.NoError(err)
+
+	spans := recorder.Ended()
+	suite.Require().NotEmpty(spans)
+	suite.Equal(trace.SpanKindClient, spans[0].SpanKind())
+	suite.False(spans[0].Parent().IsValid())
+}
@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// Store represents a store for rate limiter statuses whose keys can be listed.
+type Store interface {
+	limiter.Store
+	// Keys returns the keys stored starting with the prefix, sorted.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// PeekStore represents a store whose request logs and theoretical arrival
+// times (TAT) can be read without changing them, so the keys of the sliding
+// log, GCRA and leaky bucket rules, which do not count in the statuses, are
+// shown with them.
+type PeekStore interface {
+	// LogCount returns the number of requests in the log of a key in the
+	// window before now.
+	LogCount(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// TAT returns the TAT of a key, or the zero time if it has none.
+	TAT(ctx context.Context, key string) (time.Time, error)
+}
+
+// Limiters represents the limiters of the rules by name, as a policy.Reloader.
+type Limiters interface {
+	Limiters() map[string]*limiter.Limiter
+}
+
+type statusResponse struct {
+	Count         int       `json:"count"`
+	StartedAt     time.Time `json:"started_at"`
+	PreviousCount int       `json:"previous_count"`
+}
+
+type ruleResponse struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Limit     int    `json:"limit"`
+	Window    string `json:"window"`
+	Tier      string `json:"tier,omitempty"`
+}
+
+// keyResponse has the status of a key, or its log count for the sliding log
+// rules, or its TAT for the GCRA and leaky bucket rules, as only one of them
+// is used by the rule.
+type keyResponse struct {
+	Key      string          `json:"key"`
+	Status   *statusResponse `json:"status,omitempty"`
+	LogCount *int            `json:"log_count,omitempty"`
+	TAT      *time.Time      `json:"tat,omitempty"`
+	Rule     *ruleResponse   `json:"rule,omitempty"`
+}
+
+type handler struct {
+	store    Store
+	limiters Limiters
+	logger   *slog.Logger
+}
+
+// Option configures the admin handler.
+type Option func(*handler)
+
+// WithLogger makes the handler log to the logger instead of slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *handler) {
+		h.logger = logger
+	}
+}
+
+// NewHandler returns a handler for the administration of the keys of the store:
+//
+//   - GET /keys?prefix=<prefix> lists the keys stored starting with the prefix;
+//   - GET /keys/{key} shows the status of a key and the rule matching it, or
+//     the requests in its log or its TAT for the rules using them, if the
+//     store is a PeekStore;
+//   - DELETE /keys/{key} resets the status of a key, unblocking it.
+//
+// The rule of a key is the limiter named by the key up to its first colon,
+// as the keys of the policy rules. Every request must have the token as an
+// "Authorization: Bearer <token>" header, and an empty token rejects them all.
+//
+// The handler logs the resets and store errors to slog.Default(), unless a
+// logger is given with WithLogger.
+func NewHandler(store Store, limiters Limiters, token string, opts ...Option) http.Handler {
+	h := &handler{store: store, limiters: limiters, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", h.listKeys)
+	mux.HandleFunc("GET /keys/{key}", h.getKey)
+	mux.HandleFunc("DELETE /keys/{key}", h.resetKey)
+	return authenticate(token, mux)
+}
+
+// authenticate responds with an unauthorized status to the requests
+// without the token.
+func authenticate(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "invalid admin token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.Keys(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		h.writeStoreError(r.Context(), w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"keys": keys})
+}
+
+func (h *handler) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	s, err := h.store.Get(r.Context(), key)
+	if err != nil {
+		h.writeStoreError(r.Context(), w, err)
+		return
+	}
+	response, err := h.keyResponse(r.Context(), key, s)
+	if err != nil {
+		h.writeStoreError(r.Context(), w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *handler) resetKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	s, err := h.store.Reset(r.Context(), key)
+	if err != nil {
+		h.writeStoreError(r.Context(), w, err)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "admin key reset", slog.String("key", key))
+	response, err := h.keyResponse(r.Context(), key, s)
+	if err != nil {
+		h.writeStoreError(r.Context(), w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// keyResponse returns the response with the state of a key used by the rule
+// matching it, and the rule, or an error if the store could not be reached.
+func (h *handler) keyResponse(ctx context.Context, key string, s *status.Status) (keyResponse, error) {
+	response := keyResponse{
+		Key:    key,
+		Status: &statusResponse{Count: s.Count, StartedAt: s.StartedAt, PreviousCount: s.PreviousCount},
+	}
+	name, _, _ := strings.Cut(key, ":")
+	l, ok := h.limiters.Limiters()[name]
+	if !ok {
+		return response, nil
+	}
+	limit, duration, tier := l.KeyLimit(ctx, key)
+	response.Rule = &ruleResponse{Name: l.Rule(), Algorithm: l.Algorithm(), Limit: limit, Window: duration.String(), Tier: tier}
+	peek, ok := h.store.(PeekStore)
+	if !ok {
+		return response, nil
+	}
+	switch l.Algorithm() {
+	case "sliding_log":
+		count, err := peek.LogCount(ctx, key, time.Now(), duration)
+		if err != nil {
+			return keyResponse{}, err
+		}
+		response.Status, response.LogCount = nil, &count
+	case "gcra", "leaky_bucket":
+		tat, err := peek.TAT(ctx, key)
+		if err != nil {
+			return keyResponse{}, err
+		}
+		// A TAT in the past is taken as now by the rules
+		if now := time.Now(); tat.Before(now) {
+			tat = now
+		}
+		response.Status, response.TAT = nil, &tat
+	}
+	return response, nil
+}
+
+func (h *handler) writeStoreError(ctx context.Context, w http.ResponseWriter, err error) {
+	h.logger.ErrorContext(ctx, "admin store error", slog.Any("error", err))
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "the store is unavailable, try again later"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/stretchr/testify/suite"
+)
+
+var ctx = context.Background()
+
+// limiterMap is a static Limiters.
+type limiterMap map[string]*limiter.Limiter
+
+func (m limiterMap) Limiters() map[string]*limiter.Limiter {
+	return m
+}
+
+type AdminTestSuite struct {
+	suite.Suite
+	store   *memory.MemoryStore
+	limiter *limiter.Limiter
+	handler http.Handler
+}
+
+func (suite *AdminTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+	suite.limiter = limiter.NewLimiter(suite.store, 1, time.Minute)
+	suite.limiter.SetRule("ip")
+	suite.handler = NewHandler(suite.store, limiterMap{"ip": suite.limiter}, "secret")
+}
+
+func TestAdminSuite(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (suite *AdminTestSuite) request(method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	suite.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func (suite *AdminTestSuite) TestGivenInvalidTokenWhenRequestIsExecutedThenShouldReturnStatusUnauthorized() {
+	suite.Equal(http.StatusUnauthorized, suite.request(http.MethodGet, "/keys", "").Code)
+	suite.Equal(http.StatusUnauthorized, suite.request(http.MethodGet, "/keys", "wrong").Code)
+
+	handler := NewHandler(suite.store, limiterMap{}, "")
+	req := httptest.NewRequest(http.MethodGet, "/keys", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+}
+
+func (suite *AdminTestSuite) TestGivenKeysWhenListingKeysWithPrefixThenShouldReturnMatchingKeys() {
+	suite.limiter.Allow(ctx, "ip:10.0.0.1")
+	suite.limiter.Allow(ctx, "ip:10.0.0.2")
+	suite.limiter.Allow(ctx, "api_key:abc123")
+
+	rec := suite.request(http.MethodGet, "/keys?prefix=ip:", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	var response map[string][]string
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Equal([]string{"ip:10.0.0.1", "ip:10.0.0.2"}, response["keys"])
+}
+
+func (suite *AdminTestSuite) TestGivenLimitedKeyWhenGettingKeyThenShouldReturnStatusAndRule() {
+	suite.limiter.Allow(ctx, "ip:10.0.0.1")
+
+	rec := suite.request(http.MethodGet, "/keys/ip:10.0.0.1", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Equal("ip:10.0.0.1", response.Key)
+	suite.Equal(1, response.Status.Count)
+	suite.Equal(&ruleResponse{Name: "ip", Algorithm: "fixed_window", Limit: 1, Window: "1m0s"}, response.Rule)
+	suite.Nil(response.LogCount)
+	suite.Nil(response.TAT)
+}
+
+func (suite *AdminTestSuite) TestGivenSlidingLogKeyWhenGettingKeyThenShouldReturnLogCount() {
+	l := limiter.NewSlidingLogLimiter(suite.store, 2, time.Minute)
+	l.SetRule("log")
+	handler := NewHandler(suite.store, limiterMap{"log": l}, "secret")
+	l.Allow(ctx, "log:10.0.0.1")
+	l.Allow(ctx, "log:10.0.0.1")
+
+	req := httptest.NewRequest(http.MethodGet, "/keys/log:10.0.0.1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Nil(response.Status)
+	suite.Require().NotNil(response.LogCount)
+	suite.Equal(2, *response.LogCount)
+	suite.Equal("sliding_log", response.Rule.Algorithm)
+}
+
+func (suite *AdminTestSuite) TestGivenGCRAKeyWhenGettingKeyThenShouldReturnTAT() {
+	l := limiter.NewGCRALimiter(suite.store, 1, time.Minute, 1)
+	l.SetRule("gcra")
+	handler := NewHandler(suite.store, limiterMap{"gcra": l}, "secret")
+	l.Allow(ctx, "gcra:10.0.0.1")
+
+	req := httptest.NewRequest(http.MethodGet, "/keys/gcra:10.0.0.1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Nil(response.Status)
+	suite.Require().NotNil(response.TAT)
+	suite.WithinDuration(time.Now().Add(time.Minute), *response.TAT, time.Second)
+	suite.Equal("gcra", response.Rule.Algorithm)
+}
+
+func (suite *AdminTestSuite) TestGivenKeyWithoutRuleWhenGettingKeyThenShouldReturnStatusOnly() {
+	rec := suite.request(http.MethodGet, "/keys/other:1", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Nil(response.Rule)
+}
+
+func (suite *AdminTestSuite) TestGivenUnknownKeyWhenGettingKeyThenShouldNotStoreIt() {
+	rec := suite.request(http.MethodGet, "/keys/ip:10.0.0.9", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Equal(0, response.Status.Count)
+	suite.Zero(suite.store.Len())
+}
+
+func (suite *AdminTestSuite) TestGivenLimitedKeyWhenResettingKeyThenShouldUnblockIt() {
+	suite.limiter.Allow(ctx, "ip:10.0.0.1")
+	allowed, _, err := suite.limiter.Allow(ctx, "ip:10.0.0.1")
+	suite.NoError(err)
+	suite.False(allowed)
+
+	rec := suite.request(http.MethodDelete, "/keys/ip:10.0.0.1", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	var response keyResponse
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Equal(0, response.Status.Count)
+
+	allowed, _, err = suite.limiter.Allow(ctx, "ip:10.0.0.1")
+	suite.NoError(err)
+	suite.True(allowed)
+}
+
+func (suite *AdminTestSuite) TestGivenLoggerWhenResettingKeyThenResetIsLoggedToTheLogger() {
+	var buf bytes.Buffer
+	suite.handler = NewHandler(suite.store, limiterMap{"ip": suite.limiter}, "secret", WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	rec := suite.request(http.MethodDelete, "/keys/ip:10.0.0.1", "secret")
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(buf.String(), "admin key reset")
+	suite.Contains(buf.String(), "key=ip:10.0.0.1")
+}
@@ -1,21 +1,65 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	IPLimit        int
-	IPDuration     time.Duration
-	APIKeyLimit    int
-	APIKeyDuration time.Duration
-	RedisAddress   string
-	RedisPassword  string
+	// LogLevel is the minimum level logged, the requests start and end
+	// are only logged at the debug level.
+	LogLevel slog.Level
+	// PolicyFile is the YAML or JSON file with the rate limiting rules.
+	PolicyFile string
+	// PolicyWatchInterval is how often the policy file is checked for changes,
+	// it is only reloaded on SIGHUP if zero.
+	PolicyWatchInterval time.Duration
+	// MemoryTTL is how long the memory store keeps the statuses without
+	// changes, they are kept until reset if zero.
+	MemoryTTL time.Duration
+	// MemoryMaxKeys caps the keys in the memory store, evicting the least
+	// recently used ones, there is no cap if zero.
+	MemoryMaxKeys int
+	// RedisAddresses are the Redis nodes, or the Sentinels if RedisMasterName
+	// is set, a Cluster is used if there are many or RedisCluster is set.
+	RedisAddresses        []string
+	RedisCluster          bool
+	RedisMasterName       string
+	RedisUsername         string
+	RedisPassword         string
+	RedisSentinelPassword string
+	// RedisDB is the database index, which must be zero on a Cluster.
+	RedisDB int
+	// RedisTLS connects to Redis over TLS, verifying it with the CA
+	// certificates in RedisTLSCAFile, or the system ones if empty.
+	RedisTLS       bool
+	RedisTLSCAFile string
+	// RedisPoolSize and RedisMinIdleConns are the connections kept per node,
+	// and RedisPoolTimeout how long to wait for one, the client defaults
+	// are used if zero.
+	RedisPoolSize     int
+	RedisMinIdleConns int
+	RedisPoolTimeout  time.Duration
+	// RedisMigrateLegacyKeys moves the keys stored by earlier versions under
+	// the RedisLegacyKeyPrefixes to the names read by the rules, replacing
+	// each prefix with the one it maps to, once per Redis at startup. The
+	// statuses stored without a TTL expire after RedisLegacyTTL.
+	RedisMigrateLegacyKeys bool
+	RedisLegacyKeyPrefixes map[string]string
+	RedisLegacyTTL         time.Duration
+	// StoreFailClosed limits the requests when the store could not be reached,
+	// otherwise they are allowed.
+	StoreFailClosed  bool
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// AdminToken protects the admin API, which is disabled if empty.
+	AdminToken string
+	AdminPort  int
 }
 
 func getEnvInt(key string, defaultValue int) int {
@@ -34,23 +78,105 @@ func getEnvStr(key string, defaultValue string) string {
 	return value
 }
 
+func getEnvList(key string, defaultValue []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+// getEnvMap returns the old=new pairs of a comma separated list, skipping
+// the entries without =.
+func getEnvMap(key string, defaultValue map[string]string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range getEnvList(key, nil) {
+		if old, renamed, ok := strings.Cut(pair, "="); ok {
+			values[old] = renamed
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvLevel(key string, defaultValue slog.Level) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv(key))); err != nil {
+		return defaultValue
+	}
+	return level
+}
+
 func LoadConfig() Config {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("error loading .env file, will use environment variables")
+		slog.Warn("error loading .env file, will use environment variables")
 	}
-	ipLimit := getEnvInt("IP_LIMIT", 10)
-	ipDuration := getEnvInt("IP_LIMIT_DURATION", 1)
-	apiKeyLimit := getEnvInt("API_KEY_LIMIT", 100)
-	apiKeyDuration := getEnvInt("API_KEY_LIMIT_DURATION", 1)
-	redisAddress := getEnvStr("REDIS_ADDRESS", "localhost:6379")
+	logLevel := getEnvLevel("LOG_LEVEL", slog.LevelInfo)
+	policyFile := getEnvStr("POLICY_FILE", "policy.yaml")
+	policyWatchInterval := getEnvInt("POLICY_WATCH_INTERVAL", 0)
+	memoryTTL := getEnvInt("MEMORY_TTL", 600)
+	memoryMaxKeys := getEnvInt("MEMORY_MAX_KEYS", 0)
+	redisAddresses := getEnvList("REDIS_ADDRESS", []string{"localhost:6379"})
+	redisCluster := getEnvBool("REDIS_CLUSTER", false)
+	redisMasterName := os.Getenv("REDIS_MASTER_NAME")
+	redisUsername := os.Getenv("REDIS_USERNAME")
 	redisPassword := os.Getenv("REDIS_PASSWORD")
+	redisSentinelPassword := os.Getenv("REDIS_SENTINEL_PASSWORD")
+	redisDB := getEnvInt("REDIS_DB", 0)
+	redisTLS := getEnvBool("REDIS_TLS", false)
+	redisTLSCAFile := os.Getenv("REDIS_TLS_CA_FILE")
+	redisPoolSize := getEnvInt("REDIS_POOL_SIZE", 0)
+	redisMinIdleConns := getEnvInt("REDIS_MIN_IDLE_CONNS", 0)
+	redisPoolTimeout := getEnvInt("REDIS_POOL_TIMEOUT", 0)
+	redisMigrateLegacyKeys := getEnvBool("REDIS_MIGRATE_LEGACY_KEYS", false)
+	redisLegacyKeyPrefixes := getEnvMap("REDIS_LEGACY_KEY_PREFIXES", map[string]string{"IP:": "ip:", "API_KEY:": "api_key:"})
+	redisLegacyTTL := getEnvInt("REDIS_LEGACY_TTL", 3600)
+	storeFailClosed := getEnvBool("STORE_FAIL_CLOSED", false)
+	breakerThreshold := getEnvInt("BREAKER_THRESHOLD", 5)
+	breakerCooldown := getEnvInt("BREAKER_COOLDOWN", 5)
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	adminPort := getEnvInt("ADMIN_PORT", 8081)
 	return Config{
-		IPLimit:        ipLimit,
-		IPDuration:     time.Duration(ipDuration) * time.Second,
-		APIKeyLimit:    apiKeyLimit,
-		APIKeyDuration: time.Duration(apiKeyDuration) * time.Second,
-		RedisAddress:   redisAddress,
-		RedisPassword:  redisPassword,
+		LogLevel:               logLevel,
+		PolicyFile:             policyFile,
+		PolicyWatchInterval:    time.Duration(policyWatchInterval) * time.Second,
+		MemoryTTL:              time.Duration(memoryTTL) * time.Second,
+		MemoryMaxKeys:          memoryMaxKeys,
+		RedisAddresses:         redisAddresses,
+		RedisCluster:           redisCluster,
+		RedisMasterName:        redisMasterName,
+		RedisUsername:          redisUsername,
+		RedisPassword:          redisPassword,
+		RedisSentinelPassword:  redisSentinelPassword,
+		RedisDB:                redisDB,
+		RedisTLS:               redisTLS,
+		RedisTLSCAFile:         redisTLSCAFile,
+		RedisPoolSize:          redisPoolSize,
+		RedisMinIdleConns:      redisMinIdleConns,
+		RedisPoolTimeout:       time.Duration(redisPoolTimeout) * time.Second,
+		RedisMigrateLegacyKeys: redisMigrateLegacyKeys,
+		RedisLegacyKeyPrefixes: redisLegacyKeyPrefixes,
+		RedisLegacyTTL:         time.Duration(redisLegacyTTL) * time.Second,
+		StoreFailClosed:        storeFailClosed,
+		BreakerThreshold:       breakerThreshold,
+		BreakerCooldown:        time.Duration(breakerCooldown) * time.Second,
+		AdminToken:             adminToken,
+		AdminPort:              adminPort,
 	}
 }
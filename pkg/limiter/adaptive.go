@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// aimdIncrease is how much the limit is increased after a healthy window.
+	aimdIncrease int = 1
+	// aimdBackoff is the factor the limit is multiplied by after an unhealthy window.
+	aimdBackoff float64 = 0.9
+)
+
+// AIMD represents an additive increase, multiplicative decrease (AIMD) controller
+// that adjusts the limit of a Limiter from the latency and errors observed downstream.
+//
+// The observations are evaluated once per limiter duration. If the average latency
+// exceeds maxLatency or the error rate exceeds maxErrorRate, the limit is decreased
+// multiplicatively, otherwise it is increased additively, always within
+// minLimit and maxLimit.
+type AIMD struct {
+	limiter      *Limiter
+	minLimit     int
+	maxLimit     int
+	maxLatency   time.Duration
+	maxErrorRate float64
+
+	mu         sync.Mutex
+	startedAt  time.Time
+	requests   int
+	errors     int
+	latencySum time.Duration
+}
+
+// NewAIMD returns a new AIMD controller for the limiter.
+//
+// The minLimit and maxLimit bound the limit set on the limiter, the minLimit
+// is raised to 1 and the maxLimit to the minLimit if they are lower, as the
+// limiters require a positive limit.
+// The maxLatency is the highest average latency considered healthy.
+// The maxErrorRate is the highest ratio of failed requests considered healthy.
+func NewAIMD(l *Limiter, minLimit, maxLimit int, maxLatency time.Duration, maxErrorRate float64) *AIMD {
+	minLimit = max(minLimit, 1)
+	maxLimit = max(maxLimit, minLimit)
+	return &AIMD{
+		limiter:      l,
+		minLimit:     minLimit,
+		maxLimit:     maxLimit,
+		maxLatency:   maxLatency,
+		maxErrorRate: maxErrorRate,
+		startedAt:    time.Now(),
+	}
+}
+
+// Observe records the latency of a request and whether it failed, adjusting
+// the limit of the limiter once the limiter duration has passed since the
+// last adjustment.
+func (a *AIMD) Observe(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+	a.latencySum += latency
+	if failed {
+		a.errors++
+	}
+	if time.Since(a.startedAt) < a.limiter.Duration() {
+		return
+	}
+	a.limiter.SetLimit(a.adjust(a.limiter.Limit()))
+	a.startedAt = time.Now()
+	a.requests = 0
+	a.errors = 0
+	a.latencySum = 0
+}
+
+// adjust returns the limit adjusted from the observations of the current window.
+func (a *AIMD) adjust(limit int) int {
+	averageLatency := a.latencySum / time.Duration(a.requests)
+	errorRate := float64(a.errors) / float64(a.requests)
+	if averageLatency > a.maxLatency || errorRate > a.maxErrorRate {
+		limit = int(float64(limit) * aimdBackoff)
+	} else {
+		limit += aimdIncrease
+	}
+	return min(max(limit, a.minLimit), a.maxLimit)
+}
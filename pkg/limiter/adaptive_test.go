@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type AIMDTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *AIMDTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestAIMDSuite(t *testing.T) {
+	suite.Run(t, new(AIMDTestSuite))
+}
+
+func (suite *AIMDTestSuite) TestWhenCallingNewAIMDThenValuesAreSetup() {
+	limiter := NewLimiter(suite.store, 10, time.Second)
+	aimd := NewAIMD(limiter, 1, 20, time.Second, 0.1)
+	suite.NotNil(aimd)
+	suite.Equal(limiter, aimd.limiter)
+	suite.Equal(1, aimd.minLimit)
+	suite.Equal(20, aimd.maxLimit)
+	suite.Equal(time.Second, aimd.maxLatency)
+	suite.Equal(0.1, aimd.maxErrorRate)
+}
+
+func (suite *AIMDTestSuite) TestGivenNonPositiveBoundsWhenCallingNewAIMDThenBoundsAreAtLeastOne() {
+	limiter := NewLimiter(suite.store, 10, time.Second)
+	aimd := NewAIMD(limiter, 0, -1, time.Second, 0.1)
+	suite.Equal(1, aimd.minLimit)
+	suite.Equal(1, aimd.maxLimit)
+}
+
+func (suite *AIMDTestSuite) TestGivenZeroMinLimitWhenWindowsKeepFailingThenLimitStaysPositive() {
+	limiter := NewLimiter(suite.store, 1, 0)
+	aimd := NewAIMD(limiter, 0, 20, time.Second, 0.1)
+	for range 3 {
+		aimd.Observe(10*time.Millisecond, true)
+		suite.Equal(1, limiter.Limit())
+	}
+	allowed, _, err := limiter.Allow(ctx, "key")
+	suite.NoError(err)
+	suite.True(allowed)
+}
+
+func (suite *AIMDTestSuite) TestGivenHealthyWindowWhenCallingObserveThenLimitIsIncreased() {
+	limiter := NewLimiter(suite.store, 10, 0)
+	aimd := NewAIMD(limiter, 1, 20, time.Second, 0.1)
+	aimd.Observe(10*time.Millisecond, false)
+	suite.Equal(11, limiter.Limit())
+}
+
+func (suite *AIMDTestSuite) TestGivenSlowWindowWhenCallingObserveThenLimitIsDecreased() {
+	limiter := NewLimiter(suite.store, 10, 0)
+	aimd := NewAIMD(limiter, 1, 20, time.Second, 0.1)
+	aimd.Observe(2*time.Second, false)
+	suite.Equal(9, limiter.Limit())
+}
+
+func (suite *AIMDTestSuite) TestGivenFailingWindowWhenCallingObserveThenLimitIsDecreased() {
+	limiter := NewLimiter(suite.store, 10, 0)
+	aimd := NewAIMD(limiter, 1, 20, time.Second, 0.1)
+	aimd.Observe(10*time.Millisecond, true)
+	suite.Equal(9, limiter.Limit())
+}
+
+func (suite *AIMDTestSuite) TestGivenWindowNotFinishedWhenCallingObserveThenLimitIsKept() {
+	limiter := NewLimiter(suite.store, 10, time.Hour)
+	aimd := NewAIMD(limiter, 1, 20, time.Second, 0.1)
+	aimd.Observe(2*time.Second, true)
+	suite.Equal(10, limiter.Limit())
+	suite.Equal(1, aimd.requests)
+	suite.Equal(1, aimd.errors)
+}
+
+func (suite *AIMDTestSuite) TestGivenBoundsWhenCallingObserveThenLimitStaysWithinThem() {
+	limiter := NewLimiter(suite.store, 2, 0)
+	aimd := NewAIMD(limiter, 2, 3, time.Second, 0.1)
+	aimd.Observe(2*time.Second, false)
+	suite.Equal(2, limiter.Limit())
+
+	aimd.Observe(0, false)
+	aimd.Observe(0, false)
+	suite.Equal(3, limiter.Limit())
+}
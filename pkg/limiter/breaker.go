@@ -0,0 +1,132 @@
+package limiter
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned instead of calling the store while the breaker is open.
+var ErrBreakerOpen = errors.New("limiter store circuit breaker is open")
+
+// FailurePolicy represents how a Limiter decides requests when its store
+// could not be reached.
+type FailurePolicy int
+
+const (
+	// FailOpen allows the requests when the store could not be reached.
+	FailOpen FailurePolicy = iota
+	// FailClosed limits the requests when the store could not be reached.
+	FailClosed
+)
+
+// Breaker represents a circuit breaker that stops calling a store that
+// could not be reached.
+//
+// After threshold consecutive failures the breaker opens and no call is
+// allowed until the cooldown has passed, then a single call is allowed
+// to test the store, closing the breaker if it succeeds or opening it
+// again if it fails. Every call allowed must be settled with Success,
+// Failure or Cancel.
+//
+// It logs when it opens and closes to slog.Default(), unless a logger is
+// set with SetLogger.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	testing  bool
+}
+
+// NewBreaker returns a new closed circuit breaker.
+//
+// The threshold is the number of consecutive failures that opens the breaker.
+// The cooldown is how long the breaker stays open before testing the store.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// SetLogger sets the logger of the breaker, slog.Default() if nil.
+func (b *Breaker) SetLogger(logger *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger = logger
+}
+
+// log returns the logger of the breaker, it must be called with the lock held.
+func (b *Breaker) log() *slog.Logger {
+	if b.logger == nil {
+		return slog.Default()
+	}
+	return b.logger
+}
+
+// Allow returns true if the store can be called.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.testing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.testing = true
+	return true
+}
+
+// Cancel records a call to the store canceled before it finished, which
+// neither opens nor closes the breaker.
+//
+// A canceled test call lets another call test the store once the
+// cooldown has passed again.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.testing {
+		b.testing = false
+		b.openedAt = time.Now()
+	}
+}
+
+// Success records a successful call to the store, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures >= b.threshold {
+		b.log().Info("limiter store circuit breaker closed")
+	}
+	b.failures = 0
+	b.testing = false
+}
+
+// Failure records a failed call to the store, opening the breaker once
+// the threshold is reached.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.testing = false
+	if b.failures == b.threshold {
+		b.log().Warn(
+			"limiter store circuit breaker opened",
+			slog.Duration("cooldown", b.cooldown),
+			slog.Int("failures", b.failures),
+		)
+	}
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhenCallingNewBreakerThenBreakerIsClosed(t *testing.T) {
+	breaker := NewBreaker(2, time.Minute)
+	assert.Equal(t, 2, breaker.threshold)
+	assert.Equal(t, time.Minute, breaker.cooldown)
+	assert.True(t, breaker.Allow())
+}
+
+func TestGivenThresholdReachedWhenCallingAllowThenReturnFalseUntilCooldown(t *testing.T) {
+	breaker := NewBreaker(2, time.Minute)
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.False(t, breaker.Allow())
+}
+
+func TestGivenFailuresWhenCallingSuccessThenFailuresAreReset(t *testing.T) {
+	breaker := NewBreaker(2, time.Minute)
+	breaker.Failure()
+	breaker.Success()
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+}
+
+func TestGivenCooldownPassedWhenCallingAllowThenOnlyOneCallIsAllowed(t *testing.T) {
+	breaker := NewBreaker(1, 0)
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+
+	// A failed test opens the breaker again
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+
+	// A successful test closes the breaker
+	breaker.Success()
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.Allow())
+}
+
+func TestGivenTestCallCanceledWhenCallingAllowThenAnotherCallIsAllowedAfterCooldown(t *testing.T) {
+	breaker := NewBreaker(1, 0)
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+
+	breaker.Cancel()
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+}
+
+func TestGivenBreakerClosedWhenCallingCancelThenFailuresAreKept(t *testing.T) {
+	breaker := NewBreaker(2, time.Minute)
+	breaker.Failure()
+	breaker.Cancel()
+	breaker.Failure()
+	assert.False(t, breaker.Allow())
+}
+
+func TestGivenLoggerWhenBreakerOpensAndClosesThenItIsLoggedToTheLogger(t *testing.T) {
+	var buf bytes.Buffer
+	breaker := NewBreaker(1, 0)
+	breaker.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	breaker.Failure()
+	assert.Contains(t, buf.String(), "limiter store circuit breaker opened")
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.Contains(t, buf.String(), "limiter store circuit breaker closed")
+}
@@ -0,0 +1,56 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// SlotStore represents a store for the slots of in-flight requests per key.
+type SlotStore interface {
+	// Acquire takes one of the limit slots of a key, which is held until it
+	// is released or its lease expires.
+	//
+	// It returns the slot and true if one was taken,
+	// or an error if the store could not be reached.
+	Acquire(ctx context.Context, key string, limit int, lease time.Duration) (string, bool, error)
+	// Release frees a slot of a key.
+	Release(ctx context.Context, key string, slot string) error
+}
+
+// ConcurrencyLimiter represents a limiter of in-flight requests.
+type ConcurrencyLimiter struct {
+	store SlotStore
+	limit int
+	lease time.Duration
+}
+
+// NewConcurrencyLimiter returns a new concurrency limiter.
+//
+// The store is used to store the slots of in-flight requests.
+// The limit is the maximum number of requests in-flight at once per key.
+// The lease is the maximum time a slot is held if it is never released,
+// so slots of crashed instances are not leaked, it should be longer than
+// the longest request.
+func NewConcurrencyLimiter(store SlotStore, limit int, lease time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		store: store,
+		limit: limit,
+		lease: lease,
+	}
+}
+
+// Acquire takes a slot for a request of the key.
+//
+// It returns a function to release the slot and true if a slot was taken,
+// otherwise the key has reached the limit. It returns an error if the store
+// could not be reached.
+//
+// The release function uses its own context, as the slot must be released
+// even if the context of the request is done.
+func (c *ConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(ctx context.Context) error, bool, error) {
+	slot, ok, err := c.store.Acquire(ctx, key, c.limit, c.lease)
+	if err != nil || !ok {
+		return func(context.Context) error { return nil }, false, err
+	}
+	return func(ctx context.Context) error { return c.store.Release(ctx, key, slot) }, true, nil
+}
@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConcurrencyLimiterTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *ConcurrencyLimiterTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestConcurrencyLimiterSuite(t *testing.T) {
+	suite.Run(t, new(ConcurrencyLimiterTestSuite))
+}
+
+func (suite *ConcurrencyLimiterTestSuite) TestWhenCallingNewConcurrencyLimiterThenValuesAreSetup() {
+	limit := 5
+	lease := time.Minute
+	limiter := NewConcurrencyLimiter(suite.store, limit, lease)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(lease, limiter.lease)
+}
+
+func (suite *ConcurrencyLimiterTestSuite) TestGivenLimitReachedWhenCallingAcquireThenReturnFalse() {
+	key := "upload1"
+	limiter := NewConcurrencyLimiter(suite.store, 2, time.Minute)
+	_, ok, err := limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.True(ok)
+	_, ok, err = limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.True(ok)
+
+	release, ok, err := limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.False(ok)
+	suite.NotNil(release)
+}
+
+func (suite *ConcurrencyLimiterTestSuite) TestGivenLimitReachedWhenSlotIsReleasedThenAcquireReturnTrue() {
+	key := "upload2"
+	limiter := NewConcurrencyLimiter(suite.store, 1, time.Minute)
+	release, ok, err := limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.True(ok)
+	_, ok, err = limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.False(ok)
+
+	suite.NoError(release(ctx))
+	_, ok, err = limiter.Acquire(ctx, key)
+	suite.NoError(err)
+	suite.True(ok)
+}
@@ -0,0 +1,14 @@
+package limiter
+
+// defaultRule is the rule of the limiters without one set.
+const defaultRule = "default"
+
+// Decision represents the decision of a limiter on a request.
+type Decision struct {
+	// Allowed is true if the request is allowed.
+	Allowed bool
+	// Rule identifies the limiter that decided the request.
+	Rule string
+	// Quota is the quota of the key after the request.
+	Quota
+}
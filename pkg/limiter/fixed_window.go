@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// fixedWindow counts the requests since the status started and resets
+// the count once the duration has passed.
+//
+// The decision is left to the store if it is a WindowStore.
+type fixedWindow struct{}
+
+func (fixedWindow) allow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	s, allowed, err := takeWindow(ctx, store, key, limit, duration, cost)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	quota := Quota{
+		Limit:     limit,
+		Remaining: max(limit-s.Count, 0),
+		ResetAt:   s.StartedAt.Add(duration),
+	}
+	if !allowed {
+		quota.RetryAfter = time.Until(quota.ResetAt)
+	}
+	return allowed, quota, nil
+}
+
+// takeWindow adds cost to the count of the fixed window of a key if it stays
+// within limit, returning the status and true if it was added.
+func takeWindow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int) (*status.Status, bool, error) {
+	if w, ok := store.(WindowStore); ok {
+		return w.TakeWindow(ctx, key, time.Now(), duration, limit, cost)
+	}
+	// The status is reset once the duration has passed since it started
+	return store.Take(ctx, key, duration, func(s *status.Status) bool {
+		if s.IsExpired(duration) {
+			*s = *status.NewStatus()
+		}
+		if s.Count+cost > limit {
+			return false
+		}
+		s.Count += cost
+		return true
+	})
+}
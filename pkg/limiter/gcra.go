@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// gcra spaces the requests by duration divided by limit, allowing up to
+// burst requests at once, keeping only a theoretical arrival time (TAT)
+// per key.
+type gcra struct {
+	store TATStore
+	burst int
+}
+
+func (g gcra) allow(ctx context.Context, _ Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	now := time.Now()
+	// No request fits in a limit below 1, as in the fixed window
+	if limit < 1 {
+		return false, blockedQuota(duration), nil
+	}
+	interval := duration / time.Duration(limit)
+	tolerance := time.Duration(max(g.burst-1, 0)) * interval
+	// The last unit of the cost must be within the tolerance
+	costTolerance := tolerance - time.Duration(cost-1)*interval
+	tat, allowed, err := g.store.TakeTAT(ctx, key, now, time.Duration(cost)*interval, costTolerance)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	if !allowed {
+		quota := Quota{Limit: g.burst, Remaining: 0, ResetAt: tat, RetryAfter: duration}
+		if costTolerance >= 0 {
+			quota.RetryAfter = tat.Add(-costTolerance).Sub(now)
+		}
+		return false, quota, nil
+	}
+	tat = tat.Add(time.Duration(cost) * interval)
+	return true, Quota{
+		Limit:     g.burst,
+		Remaining: tatRemaining(tat, now, interval, tolerance),
+		ResetAt:   tat,
+	}, nil
+}
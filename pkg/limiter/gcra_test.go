@@ -0,0 +1,95 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type GCRATestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *GCRATestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestGCRASuite(t *testing.T) {
+	suite.Run(t, new(GCRATestSuite))
+}
+
+func (suite *GCRATestSuite) TestWhenCallingNewGCRALimiterThenValuesAreSetup() {
+	limit := 5
+	duration := time.Second
+	burst := 2
+	limiter := NewGCRALimiter(suite.store, limit, duration, burst)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(duration, limiter.duration)
+	suite.Equal(gcra{store: suite.store, burst: burst}, limiter.algorithm)
+}
+
+func (suite *GCRATestSuite) TestGivenKeyDoesNotExistsWhenCallingAllowThenBurstIsAllowed() {
+	key := "gcra1"
+	limiter := NewGCRALimiter(suite.store, 1, time.Minute, 3)
+	for i := range 3 {
+		allowed, quota, err := limiter.Allow(ctx, key)
+		suite.NoError(err)
+		suite.True(allowed)
+		suite.Zero(quota.RetryAfter)
+		suite.Equal(3, quota.Limit)
+		suite.Equal(2-i, quota.Remaining)
+	}
+	suite.True(shouldLimit(suite.T(), limiter, key))
+}
+
+func (suite *GCRATestSuite) TestGivenBurstReachedWhenCallingAllowThenReturnExactRetryAfter() {
+	key := "gcra2"
+	limiter := NewGCRALimiter(suite.store, 1, time.Minute, 2)
+	limiter.Allow(ctx, key)
+	limiter.Allow(ctx, key)
+
+	// The first request is one minute away from being emitted
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Zero(quota.Remaining)
+	suite.InDelta(time.Minute, quota.RetryAfter, float64(time.Second))
+	suite.WithinDuration(time.Now().Add(2*time.Minute), quota.ResetAt, time.Second)
+}
+
+func (suite *GCRATestSuite) TestGivenNoBurstWhenCallingAllowThenRequestsAreSpaced() {
+	key := "gcra3"
+	limiter := NewGCRALimiter(suite.store, 10, time.Second, 1)
+	allowed, _, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.InDelta(100*time.Millisecond, quota.RetryAfter, float64(10*time.Millisecond))
+
+	time.Sleep(quota.RetryAfter)
+	allowed, _, err = limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+}
+
+func (suite *GCRATestSuite) TestGivenZeroLimitWhenCallingAllowThenRequestIsLimitedWithoutPanicking() {
+	limiter := NewGCRALimiter(suite.store, 0, time.Second, 5)
+
+	allowed, quota, err := limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(time.Second, quota.RetryAfter)
+
+	limiter = NewGCRALimiter(suite.store, 1, time.Second, 5)
+	limiter.SetLimit(0)
+	allowed, _, err = limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+}
@@ -0,0 +1,58 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// leakyBucket queues the requests and lets them leave spaced by duration
+// divided by limit, limiting the requests that would wait longer than
+// maxWait or would exceed queueDepth requests waiting.
+//
+// It keeps only a theoretical arrival time (TAT) per key, which is when
+// the next request leaves the queue.
+type leakyBucket struct {
+	store      TATStore
+	maxWait    time.Duration
+	queueDepth int
+}
+
+func (b leakyBucket) allow(ctx context.Context, _ Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	now := time.Now()
+	// No request fits in a limit below 1, as in the fixed window
+	if limit < 1 {
+		return false, blockedQuota(duration), nil
+	}
+	interval := duration / time.Duration(limit)
+	tolerance := min(b.maxWait, time.Duration(max(b.queueDepth, 0))*interval)
+	// The last unit of the cost must leave the queue within the tolerance
+	costTolerance := tolerance - time.Duration(cost-1)*interval
+	tat, allowed, err := b.store.TakeTAT(ctx, key, now, time.Duration(cost)*interval, costTolerance)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	if !allowed {
+		quota := Quota{Limit: b.queueDepth + 1, Remaining: 0, ResetAt: tat, RetryAfter: duration}
+		if costTolerance >= 0 {
+			quota.RetryAfter = tat.Add(-costTolerance).Sub(now)
+		}
+		return false, quota, nil
+	}
+	resetAt := tat.Add(time.Duration(cost) * interval)
+	return true, Quota{
+		Limit:     b.queueDepth + 1,
+		Remaining: tatRemaining(resetAt, now, interval, tolerance),
+		ResetAt:   resetAt,
+		Delay:     tat.Sub(now),
+	}, nil
+}
+
+// cancel gives back the turn of a request canceled while waiting in the
+// queue, moving the TAT back by its cost.
+func (b leakyBucket) cancel(ctx context.Context, key string, limit int, duration time.Duration, cost int) error {
+	if limit < 1 {
+		return nil
+	}
+	interval := duration / time.Duration(limit)
+	return b.store.ReturnTAT(ctx, key, time.Now(), time.Duration(cost)*interval)
+}
@@ -0,0 +1,140 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type LeakyBucketTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *LeakyBucketTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestLeakyBucketSuite(t *testing.T) {
+	suite.Run(t, new(LeakyBucketTestSuite))
+}
+
+func (suite *LeakyBucketTestSuite) TestWhenCallingNewLeakyBucketLimiterThenValuesAreSetup() {
+	limit := 5
+	duration := time.Second
+	limiter := NewLeakyBucketLimiter(suite.store, limit, duration, time.Minute, 3)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(duration, limiter.duration)
+	suite.Equal(leakyBucket{store: suite.store, maxWait: time.Minute, queueDepth: 3}, limiter.algorithm)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenQueueWhenCallingAllowThenRequestsWaitForTheirTurn() {
+	key := "leaky1"
+	limiter := NewLeakyBucketLimiter(suite.store, 1, time.Second, time.Minute, 2)
+
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Zero(quota.Delay)
+	suite.Equal(3, quota.Limit)
+	suite.Equal(2, quota.Remaining)
+
+	allowed, quota, err = limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.InDelta(time.Second, quota.Delay, float64(10*time.Millisecond))
+	suite.Equal(1, quota.Remaining)
+
+	allowed, quota, err = limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.InDelta(2*time.Second, quota.Delay, float64(10*time.Millisecond))
+	suite.Equal(0, quota.Remaining)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenQueueDepthReachedWhenCallingAllowThenReturnFalse() {
+	key := "leaky2"
+	limiter := NewLeakyBucketLimiter(suite.store, 1, time.Second, time.Minute, 1)
+	limiter.Allow(ctx, key)
+	limiter.Allow(ctx, key)
+
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.InDelta(time.Second, quota.RetryAfter, float64(10*time.Millisecond))
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenMaxWaitReachedWhenCallingAllowThenReturnFalse() {
+	key := "leaky3"
+	limiter := NewLeakyBucketLimiter(suite.store, 1, time.Second, 1500*time.Millisecond, 10)
+	limiter.Allow(ctx, key)
+	limiter.Allow(ctx, key)
+
+	allowed, _, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenQueuedRequestWhenCallingWaitThenWaitForItsTurn() {
+	key := "leaky4"
+	limiter := NewLeakyBucketLimiter(suite.store, 10, time.Second, time.Second, 10)
+	limiter.Allow(ctx, key)
+
+	start := time.Now()
+	allowed, _, err := limiter.Wait(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.GreaterOrEqual(time.Since(start), 90*time.Millisecond)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenQueuedRequestWhenContextIsDoneThenWaitReturnsContextError() {
+	key := "leaky5"
+	limiter := NewLeakyBucketLimiter(suite.store, 1, time.Minute, time.Hour, 10)
+	limiter.Allow(ctx, key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	allowed, _, err := limiter.Wait(ctx, key)
+	suite.ErrorIs(err, context.DeadlineExceeded)
+	suite.False(allowed)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenQueuedRequestWhenContextIsDoneThenItsTurnIsGivenBack() {
+	key := "leaky6"
+	limiter := NewLeakyBucketLimiter(suite.store, 1, time.Minute, time.Hour, 1)
+	limiter.Allow(ctx, key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := limiter.Wait(ctx, key)
+	suite.ErrorIs(err, context.DeadlineExceeded)
+
+	// The queue of one request has room again
+	allowed, quota, err := limiter.Allow(context.Background(), key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Greater(quota.Delay, 59*time.Second)
+	allowed, _, err = limiter.Allow(context.Background(), key)
+	suite.NoError(err)
+	suite.False(allowed)
+}
+
+func (suite *LeakyBucketTestSuite) TestGivenZeroLimitWhenCallingAllowThenRequestIsLimitedWithoutPanicking() {
+	limiter := NewLeakyBucketLimiter(suite.store, 0, time.Second, time.Minute, 3)
+
+	allowed, quota, err := limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(time.Second, quota.RetryAfter)
+
+	limiter = NewLeakyBucketLimiter(suite.store, 1, time.Second, time.Minute, 3)
+	limiter.SetLimit(0)
+	allowed, _, err = limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+}
@@ -1,52 +1,479 @@
 package limiter
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Store represents a store for rate limiter statuses.
+//
+// The methods return an error if the store could not be reached,
+// in which case the status stored is left untouched.
 type Store interface {
-	Get(key string) *status.Status
-	Increment(key string) *status.Status
-	Reset(key string) *status.Status
+	Get(ctx context.Context, key string) (*status.Status, error)
+	Increment(ctx context.Context, key string) (*status.Status, error)
+	// IncrementBy atomically increments the count of a key by n.
+	IncrementBy(ctx context.Context, key string, n int) (*status.Status, error)
+	Reset(ctx context.Context, key string) (*status.Status, error)
+	Set(ctx context.Context, key string, s *status.Status) (*status.Status, error)
+	// Take atomically passes the status of a key, or a new status if it does
+	// not exist, to the take function, which changes it and returns true to
+	// allow the request. The status changed is only stored if it was allowed.
+	//
+	// The status stored is kept for at least ttl, after which it is the same
+	// as a new status to the limiter, or as long as it was if ttl is zero.
+	// It returns the status changed and true if the request was allowed.
+	Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error)
+}
+
+// LogStore represents a store for rate limiter statuses that also keeps
+// a log with the time of each request allowed per key.
+type LogStore interface {
+	Store
+	// Record adds the request time cost times to the log of a key if at most
+	// limit requests would be recorded in the window before it.
+	//
+	// It returns true if the request was recorded and the number of requests
+	// in the window, otherwise it returns false and how long until enough of
+	// the oldest requests counted leave the window.
+	Record(ctx context.Context, key string, at time.Time, window time.Duration, limit, cost int) (bool, int, time.Duration, error)
+}
+
+// TATStore represents a store for rate limiter statuses that also keeps
+// a theoretical arrival time (TAT) per key.
+type TATStore interface {
+	Store
+	// TakeTAT moves the TAT of a key forward by the interval if it is at most
+	// tolerance after now, a TAT in the past is taken as now.
+	//
+	// It returns the TAT before it was moved and true if it was moved.
+	TakeTAT(ctx context.Context, key string, now time.Time, interval, tolerance time.Duration) (time.Time, bool, error)
+	// ReturnTAT moves the TAT of a key back by the interval if it is after
+	// now, but not before now.
+	ReturnTAT(ctx context.Context, key string, now time.Time, interval time.Duration) error
+}
+
+// WindowStore represents a store for rate limiter statuses that also takes
+// the fixed window decision of a key in a single atomic call.
+type WindowStore interface {
+	Store
+	// TakeWindow adds cost to the count of the fixed window of a key if it
+	// stays within limit, starting a new window at now once the window has
+	// passed since the status started.
+	//
+	// It returns the status and true if the cost was added.
+	TakeWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit, cost int) (*status.Status, bool, error)
+}
+
+// BucketStore represents a store for rate limiter statuses that also takes
+// the token bucket decision of a key in a single atomic call.
+type BucketStore interface {
+	Store
+	// TakeBucket refills the token bucket of a key with a token per interval
+	// passed since the status started and takes cost tokens from it if at
+	// most burst tokens would be taken.
+	//
+	// It returns the status refilled and true if the tokens were taken.
+	TakeBucket(ctx context.Context, key string, now time.Time, interval time.Duration, burst, cost int) (*status.Status, bool, error)
+}
+
+// SlidingWindowStore represents a store for rate limiter statuses that also
+// takes the sliding window counter decision of a key in a single atomic call.
+type SlidingWindowStore interface {
+	Store
+	// TakeSlidingWindow moves the status of a key to the fixed window
+	// containing now and adds cost to its count if the weighted count of the
+	// rolling window stays below limit.
+	//
+	// It returns the status moved and true if the cost was added.
+	TakeSlidingWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit, cost int) (*status.Status, bool, error)
+}
+
+// algorithm represents the strategy used by a Limiter to decide
+// if a key has reached the limit, consuming cost units of it per request.
+type algorithm interface {
+	allow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error)
+}
+
+// canceler is implemented by the algorithms queuing the requests, to give
+// back the turn of a request canceled while waiting for it.
+type canceler interface {
+	cancel(ctx context.Context, key string, limit int, duration time.Duration, cost int) error
 }
 
 // Limiter represents a rate limiter.
 type Limiter struct {
-	store    Store
-	limit    int
-	duration time.Duration
+	store     Store
+	limit     int
+	duration  time.Duration
+	algorithm algorithm
+	policy    FailurePolicy
+	breaker   *Breaker
+	rule      string
+	overrides *Overrides
+	observer  Observer
+	provider  trace.TracerProvider
+	mu        sync.RWMutex
 }
 
-// NewLimiter returns a new rate limiter.
+// NewLimiter returns a new fixed window rate limiter.
 //
 // The store is used to store the statuses.
 // The limit is the maximum number of requests allowed in the duration.
 // The duration is the time window in which the limit is enforced.
 func NewLimiter(store Store, limit int, duration time.Duration) *Limiter {
 	return &Limiter{
-		store:    store,
-		limit:    limit,
-		duration: duration,
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: fixedWindow{},
+	}
+}
+
+// NewTokenBucketLimiter returns a new token bucket rate limiter.
+//
+// The store is used to store the statuses.
+// The bucket is refilled at a rate of limit tokens per duration.
+// The burst is the capacity of the bucket, which is the maximum number
+// of requests allowed at once.
+func NewTokenBucketLimiter(store Store, limit int, duration time.Duration, burst int) *Limiter {
+	return &Limiter{
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: tokenBucket{burst: burst},
+	}
+}
+
+// NewSlidingLogLimiter returns a new sliding window log rate limiter.
+//
+// The store is used to store the log of requests.
+// The limit is the maximum number of requests allowed in any rolling duration.
+// The duration is the size of the rolling window.
+func NewSlidingLogLimiter(store LogStore, limit int, duration time.Duration) *Limiter {
+	return &Limiter{
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: slidingLog{store: store},
+	}
+}
+
+// NewSlidingWindowLimiter returns a new sliding window counter rate limiter.
+//
+// The store is used to store the statuses.
+// The limit is the maximum number of requests allowed in any rolling duration,
+// estimated from the counts of the current and previous fixed windows.
+// The duration is the size of the rolling and fixed windows.
+func NewSlidingWindowLimiter(store Store, limit int, duration time.Duration) *Limiter {
+	return &Limiter{
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: slidingWindow{},
+	}
+}
+
+// NewGCRALimiter returns a new generic cell rate algorithm (GCRA) rate limiter.
+//
+// The store is used to store the theoretical arrival time of each key.
+// The requests are spaced by duration divided by limit.
+// The burst is the maximum number of requests allowed at once.
+func NewGCRALimiter(store TATStore, limit int, duration time.Duration, burst int) *Limiter {
+	return &Limiter{
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: gcra{store: store, burst: burst},
+	}
+}
+
+// NewLeakyBucketLimiter returns a new leaky bucket rate limiter that queues
+// the requests instead of limiting them right away.
+//
+// The store is used to store the theoretical arrival time of each key.
+// The requests leave the queue spaced by duration divided by limit.
+// The maxWait is the maximum time a request waits in the queue and
+// the queueDepth is the maximum number of requests waiting in it,
+// requests past those are limited.
+func NewLeakyBucketLimiter(store TATStore, limit int, duration time.Duration, maxWait time.Duration, queueDepth int) *Limiter {
+	return &Limiter{
+		store:     store,
+		limit:     limit,
+		duration:  duration,
+		algorithm: leakyBucket{store: store, maxWait: maxWait, queueDepth: queueDepth},
 	}
 }
 
 // GetStatus returns the status of a key.
-func (l *Limiter) GetStatus(key string) *status.Status {
-	return l.store.Get(key)
+func (l *Limiter) GetStatus(ctx context.Context, key string) (*status.Status, error) {
+	return l.store.Get(ctx, key)
 }
 
-// ShouldLimit returns true if the key has reached the limit.
-func (l *Limiter) ShouldLimit(key string) bool {
-	status := l.store.Get(key)
-	if status.IsExpired(l.duration) {
-		status = l.store.Reset(key)
+// Limit returns the current limit of the limiter.
+func (l *Limiter) Limit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limit
+}
+
+// SetLimit changes the limit of the limiter.
+//
+// It is safe to call while the limiter is in use and keeps the statuses stored.
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Duration returns the current duration of the limiter.
+func (l *Limiter) Duration() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.duration
+}
+
+// SetDuration changes the duration of the limiter.
+//
+// It is safe to call while the limiter is in use and keeps the statuses stored.
+func (l *Limiter) SetDuration(duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.duration = duration
+}
+
+// SetFailurePolicy changes how requests are decided when the store could not be reached.
+//
+// The limiters fail open by default.
+func (l *Limiter) SetFailurePolicy(policy FailurePolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+// SetBreaker sets a circuit breaker to stop calling the store once it could
+// not be reached for a while, requests are decided by the failure policy
+// while the breaker is open.
+//
+// The limiters have no breaker by default.
+func (l *Limiter) SetBreaker(breaker *Breaker) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.breaker = breaker
+}
+
+// Rule returns the rule identifying the limiter, "default" unless set.
+func (l *Limiter) Rule() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.rule == "" {
+		return defaultRule
 	}
-	if status.ReachedLimit(l.limit) {
-		return true
+	return l.rule
+}
+
+// Algorithm returns the name of the algorithm of the limiter, as the ones of
+// the policy rules: fixed_window, token_bucket, sliding_log, sliding_window,
+// gcra or leaky_bucket.
+func (l *Limiter) Algorithm() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	switch l.algorithm.(type) {
+	case tokenBucket:
+		return "token_bucket"
+	case slidingLog:
+		return "sliding_log"
+	case slidingWindow:
+		return "sliding_window"
+	case gcra:
+		return "gcra"
+	case leakyBucket:
+		return "leaky_bucket"
+	default:
+		return "fixed_window"
 	}
-	l.store.Increment(key)
-	return false
+}
+
+// SetRule changes the rule identifying the limiter in its decisions.
+func (l *Limiter) SetRule(rule string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rule = rule
+}
+
+// Overrides returns the overrides registry of the limiter, or nil if not set.
+func (l *Limiter) Overrides() *Overrides {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.overrides
+}
+
+// SetOverrides sets the overrides registry consulted for the limit and
+// duration of each key before the ones of the limiter.
+//
+// The limiters have no overrides by default.
+func (l *Limiter) SetOverrides(overrides *Overrides) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides = overrides
+}
+
+// SetObserver sets an observer notified of every decision of the limiter
+// and every call it makes to the store.
+//
+// The limiters have no observer by default.
+func (l *Limiter) SetObserver(observer Observer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observer = observer
+}
+
+// SetTracerProvider sets the OpenTelemetry tracer provider of the spans
+// started for each request checked by the limiter.
+//
+// The limiters use the global tracer provider by default.
+func (l *Limiter) SetTracerProvider(provider trace.TracerProvider) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.provider = provider
+}
+
+// KeyLimit returns the limit and duration applied to a key, which are the ones
+// of its tier in the overrides, if any, along with the tier name, or the ones
+// of the limiter and an empty tier name otherwise.
+func (l *Limiter) KeyLimit(ctx context.Context, key string) (int, time.Duration, string) {
+	l.mu.RLock()
+	limit, duration, overrides := l.limit, l.duration, l.overrides
+	l.mu.RUnlock()
+
+	if overrides != nil {
+		if tier, ok := overrides.Tier(ctx, key); ok {
+			return tier.Limit, tier.Duration, tier.Name
+		}
+	}
+	return limit, duration, ""
+}
+
+// Allow returns true if a request for the key is allowed, otherwise false,
+// along with the quota of the key after the request.
+//
+// It returns an error if the store could not be reached or the breaker is open,
+// along with whether the request is allowed by the failure policy.
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, Quota, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN is like Allow but the request consumes n units of the limit,
+// n below one is taken as one.
+func (l *Limiter) AllowN(ctx context.Context, key string, n int) (allowed bool, quota Quota, err error) {
+	l.mu.RLock()
+	policy, breaker, observer, provider := l.policy, l.breaker, l.observer, l.provider
+	l.mu.RUnlock()
+
+	rule := l.Rule()
+	ctx, span := tracer(provider).Start(ctx, "rate_limiter.check", trace.WithAttributes(
+		attribute.String("rate_limiter.rule", rule),
+		attribute.String("rate_limiter.key_hash", hashKey(key)),
+		attribute.Int("rate_limiter.cost", max(n, 1)),
+	))
+	var tier string
+	var storeLatency time.Duration
+	defer func() { endSpan(span, allowed, quota, tier, storeLatency, err) }()
+	if observer != nil {
+		defer func() { observer.ObserveDecision(rule, allowed) }()
+	}
+	limit, duration, tier := l.KeyLimit(ctx, key)
+
+	if breaker != nil && !breaker.Allow() {
+		return policy == FailOpen, Quota{}, ErrBreakerOpen
+	}
+	start := time.Now()
+	allowed, quota, err = l.algorithm.allow(ctx, l.store, key, limit, duration, max(n, 1))
+	storeLatency = time.Since(start)
+	if observer != nil {
+		observer.ObserveStore(rule, storeLatency, err)
+	}
+	if breaker != nil {
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// A canceled request says nothing about the store
+			breaker.Cancel()
+		case err != nil:
+			breaker.Failure()
+		default:
+			breaker.Success()
+		}
+	}
+	if err != nil {
+		return policy == FailOpen, Quota{}, err
+	}
+	quota.Window = duration
+	return allowed, quota, nil
+}
+
+// Wait returns true once a request for the key can be handled, waiting
+// for its turn if it was queued, or false if the key has reached the limit,
+// along with the quota of the key after the request.
+//
+// It returns an error as Allow does, along with whether the request is
+// allowed by the failure policy, or the context error if the context is
+// done while waiting, in which case the turn of the request is given back.
+func (l *Limiter) Wait(ctx context.Context, key string) (bool, Quota, error) {
+	return l.WaitN(ctx, key, 1)
+}
+
+// WaitN is like Wait but the request consumes n units of the limit,
+// n below one is taken as one.
+func (l *Limiter) WaitN(ctx context.Context, key string, n int) (bool, Quota, error) {
+	allowed, quota, err := l.AllowN(ctx, key, n)
+	if err != nil {
+		return allowed, quota, err
+	}
+	if !allowed || quota.Delay <= 0 {
+		return allowed, quota, nil
+	}
+	timer := time.NewTimer(quota.Delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true, quota, nil
+	case <-ctx.Done():
+		if c, ok := l.algorithm.(canceler); ok {
+			// The turn is kept if the store could not be reached
+			detached := context.WithoutCancel(ctx)
+			limit, duration, _ := l.KeyLimit(detached, key)
+			c.cancel(detached, key, limit, duration, max(n, 1))
+		}
+		return false, quota, ctx.Err()
+	}
+}
+
+// Decide waits for a request for the key as Wait does and returns the
+// decision of the limiter on it.
+//
+// It returns an error as Wait does, along with the decision of the failure
+// policy, in which case the decision has no quota.
+func (l *Limiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return l.DecideN(ctx, key, 1)
+}
+
+// DecideN is like Decide but the request consumes n units of the limit,
+// n below one is taken as one.
+func (l *Limiter) DecideN(ctx context.Context, key string, n int) (Decision, error) {
+	allowed, quota, err := l.WaitN(ctx, key, n)
+	return Decision{Allowed: allowed, Rule: l.Rule(), Quota: quota}, err
+}
+
+// ShouldLimit returns true if the key has reached the limit.
+//
+// It waits for the turn of the request if it was queued,
+// and returns an error as Wait does.
+func (l *Limiter) ShouldLimit(ctx context.Context, key string) (bool, error) {
+	allowed, _, err := l.Wait(ctx, key)
+	return !allowed, err
 }
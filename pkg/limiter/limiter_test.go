@@ -1,13 +1,21 @@
 package limiter
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
+var ctx = context.Background()
+
 type LimiterTestSuite struct {
 	suite.Suite
 	store *memory.MemoryStore
@@ -21,6 +29,14 @@ func TestSuite(t *testing.T) {
 	suite.Run(t, new(LimiterTestSuite))
 }
 
+// shouldLimit returns the result of ShouldLimit failing the test on error.
+func shouldLimit(t *testing.T, l *Limiter, key string) bool {
+	t.Helper()
+	limited, err := l.ShouldLimit(ctx, key)
+	require.NoError(t, err)
+	return limited
+}
+
 func (suite *LimiterTestSuite) TestWhenCallingNewLimiterThenValuesAreSetup() {
 	limit := 5
 	duration := time.Second
@@ -36,7 +52,8 @@ func (suite *LimiterTestSuite) TestGivenKeyDoesNotExistsWhenCallingGetStatusThen
 	limit := 5
 	duration := time.Second
 	limiter := NewLimiter(suite.store, limit, duration)
-	status := limiter.GetStatus(key)
+	status, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.NotNil(status)
 	suite.Equal(0, status.Count)
 	suite.LessOrEqual(time.Since(status.StartedAt), time.Second)
@@ -48,17 +65,19 @@ func (suite *LimiterTestSuite) TestGivenKeyExistsWhenCallingGetStatusThenReturnS
 	limiter := NewLimiter(suite.store, limit, duration)
 
 	key1 := "count1"
-	suite.store.Increment(key1)
+	suite.store.Increment(ctx, key1)
 	key2 := "count2"
-	suite.store.Increment(key2)
-	suite.store.Increment(key2)
+	suite.store.Increment(ctx, key2)
+	suite.store.Increment(ctx, key2)
 
-	status1 := limiter.GetStatus(key1)
+	status1, err := limiter.GetStatus(ctx, key1)
+	suite.NoError(err)
 	suite.NotNil(status1)
 	suite.Equal(1, status1.Count)
 	suite.LessOrEqual(time.Since(status1.StartedAt), time.Second)
 
-	status2 := limiter.GetStatus(key2)
+	status2, err := limiter.GetStatus(ctx, key2)
+	suite.NoError(err)
 	suite.NotNil(status2)
 	suite.Equal(2, status2.Count)
 	suite.LessOrEqual(time.Since(status2.StartedAt), time.Second)
@@ -69,57 +88,370 @@ func (suite *LimiterTestSuite) TestGivenKeyDoesNotExistsWhenCallingShouldLimitTh
 	limit := 5
 	duration := time.Second
 	limiter := NewLimiter(suite.store, limit, duration)
-	shouldLimit := limiter.ShouldLimit(key)
+	shouldLimit, err := limiter.ShouldLimit(ctx, key)
+	suite.NoError(err)
 	suite.False(shouldLimit)
 }
 
 func (suite *LimiterTestSuite) TestGivenKeyExistsAndLimitNotReachedWhenCallingShouldLimitThenReturnFalseAndIncrement() {
 	key := "count1"
-	suite.store.Increment(key)
+	suite.store.Increment(ctx, key)
 
 	limit := 5
 	duration := time.Minute
 	limiter := NewLimiter(suite.store, limit, duration)
 
-	status := limiter.GetStatus(key)
+	status, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.Equal(1, status.Count)
 
-	shouldLimit := limiter.ShouldLimit(key)
+	shouldLimit, err := limiter.ShouldLimit(ctx, key)
+	suite.NoError(err)
 	suite.False(shouldLimit)
-	status = limiter.GetStatus(key)
+	status, err = limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.Equal(2, status.Count)
 }
 
 func (suite *LimiterTestSuite) TestGivenKeyExistsAndLimitReachedWhenCallingShouldLimitThenReturnTrueWithoutIncrement() {
 	key := "count2"
-	suite.store.Increment(key)
-	suite.store.Increment(key)
+	suite.store.Increment(ctx, key)
+	suite.store.Increment(ctx, key)
 
 	limit := 2
 	duration := time.Minute
 	limiter := NewLimiter(suite.store, limit, duration)
 
-	status := limiter.GetStatus(key)
+	status, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.Equal(2, status.Count)
 
-	shouldLimit := limiter.ShouldLimit(key)
+	shouldLimit, err := limiter.ShouldLimit(ctx, key)
+	suite.NoError(err)
 	suite.True(shouldLimit)
-	status = limiter.GetStatus(key)
+	status, err = limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.Equal(2, status.Count)
 }
 
 func (suite *LimiterTestSuite) TestGivenKeyExistsAndDurationReachedWhenCallingShouldLimitThenStatusIsResetAndIncrement() {
 	key := "count3"
-	suite.store.Increment(key)
-	suite.store.Increment(key)
-	suite.store.Increment(key)
+	suite.store.Increment(ctx, key)
+	suite.store.Increment(ctx, key)
+	suite.store.Increment(ctx, key)
 
 	limit := 2
 	duration := 0 * time.Second
 	limiter := NewLimiter(suite.store, limit, duration)
 
-	shouldLimit := limiter.ShouldLimit(key)
+	shouldLimit, err := limiter.ShouldLimit(ctx, key)
+	suite.NoError(err)
 	suite.False(shouldLimit)
-	status := limiter.GetStatus(key)
+	status, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
 	suite.Equal(1, status.Count)
 }
+
+func (suite *LimiterTestSuite) TestGivenKeyExistsAndLimitReachedWhenCallingAllowThenReturnFalseAndRetryAfterWindowEnds() {
+	key := "count4"
+	suite.store.Increment(ctx, key)
+
+	limit := 1
+	duration := time.Minute
+	limiter := NewLimiter(suite.store, limit, duration)
+
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.InDelta(time.Minute, quota.RetryAfter, float64(time.Second))
+	suite.Equal(1, quota.Limit)
+	suite.Zero(quota.Remaining)
+	suite.Equal(time.Minute, quota.Window)
+}
+
+func (suite *LimiterTestSuite) TestGivenLimitChangedWhenCallingShouldLimitThenNewLimitIsUsedKeepingStatus() {
+	key := "count5"
+	limiter := NewLimiter(suite.store, 1, time.Minute)
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+
+	limiter.SetLimit(2)
+	suite.Equal(2, limiter.Limit())
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(2, s.Count)
+}
+
+// failingStore is a Store that cannot be reached.
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Increment(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) IncrementBy(ctx context.Context, key string, n int) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Reset(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Set(ctx context.Context, key string, s *status.Status) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error) {
+	return nil, false, errors.New("store unavailable")
+}
+
+func (suite *LimiterTestSuite) TestGivenStoreCannotBeReachedWhenCallingShouldLimitThenReturnError() {
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	// The limiters fail open by default
+	limited, err := limiter.ShouldLimit(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+	suite.False(limited)
+
+	_, err = limiter.GetStatus(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+}
+
+func (suite *LimiterTestSuite) TestGivenParallelRequestsWhenCallingShouldLimitThenExactlyLimitAreAllowed() {
+	key := "parallel"
+	limit := 10
+	limiters := map[string]*Limiter{
+		"fixed window":   NewLimiter(suite.store, limit, time.Minute),
+		"token bucket":   NewTokenBucketLimiter(suite.store, 1, time.Minute, limit),
+		"sliding window": NewSlidingWindowLimiter(suite.store, limit, time.Minute),
+		"sliding log":    NewSlidingLogLimiter(suite.store, limit, time.Minute),
+		"gcra":           NewGCRALimiter(suite.store, 1, time.Minute, limit),
+	}
+	for name, limiter := range limiters {
+		var allowed atomic.Int64
+		var wg sync.WaitGroup
+		for range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				limited, err := limiter.ShouldLimit(ctx, name+key)
+				if err == nil && !limited {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		suite.Equal(int64(limit), allowed.Load(), name)
+	}
+}
+
+func (suite *LimiterTestSuite) TestGivenFailClosedPolicyWhenStoreCannotBeReachedThenShouldLimit() {
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	limiter.SetFailurePolicy(FailClosed)
+	limited, err := limiter.ShouldLimit(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+	suite.True(limited)
+}
+
+func (suite *LimiterTestSuite) TestGivenBreakerWhenStoreFailsThenBreakerOpensAndPolicyIsApplied() {
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	limiter.SetFailurePolicy(FailClosed)
+	limiter.SetBreaker(NewBreaker(2, time.Minute))
+
+	_, err := limiter.ShouldLimit(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+	_, err = limiter.ShouldLimit(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+
+	limited, err := limiter.ShouldLimit(ctx, "key")
+	suite.ErrorIs(err, ErrBreakerOpen)
+	suite.True(limited)
+}
+
+func (suite *LimiterTestSuite) TestGivenHalfOpenBreakerWhenTestRequestIsCanceledThenStoreIsTestedAgain() {
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	limiter.SetBreaker(NewBreaker(1, 0))
+
+	_, _, err := limiter.Allow(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+
+	// The canceled test request neither fails nor keeps the breaker testing
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = limiter.Allow(canceled, "key")
+	suite.EqualError(err, "store unavailable")
+
+	_, _, err = limiter.Allow(ctx, "key")
+	suite.EqualError(err, "store unavailable")
+}
+
+// recordingObserver is an Observer that records what it is notified of.
+type recordingObserver struct {
+	mu          sync.Mutex
+	decisions   []bool
+	storeCalls  int
+	storeErrors int
+	rules       []string
+}
+
+func (o *recordingObserver) ObserveDecision(rule string, allowed bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.decisions = append(o.decisions, allowed)
+	o.rules = append(o.rules, rule)
+}
+
+func (o *recordingObserver) ObserveStore(rule string, latency time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.storeCalls++
+	if err != nil {
+		o.storeErrors++
+	}
+}
+
+func (suite *LimiterTestSuite) TestGivenObserverWhenDecidingRequestsThenDecisionsAndStoreCallsAreObserved() {
+	observer := &recordingObserver{}
+	limiter := NewLimiter(suite.store, 1, time.Minute)
+	limiter.SetRule("ip")
+	limiter.SetObserver(observer)
+
+	shouldLimit(suite.T(), limiter, "observed")
+	shouldLimit(suite.T(), limiter, "observed")
+
+	suite.Equal([]bool{true, false}, observer.decisions)
+	suite.Equal([]string{"ip", "ip"}, observer.rules)
+	suite.Equal(2, observer.storeCalls)
+	suite.Zero(observer.storeErrors)
+}
+
+func (suite *LimiterTestSuite) TestGivenObserverWhenStoreFailsThenErrorAndPolicyDecisionAreObserved() {
+	observer := &recordingObserver{}
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	limiter.SetFailurePolicy(FailClosed)
+	limiter.SetBreaker(NewBreaker(1, time.Minute))
+	limiter.SetObserver(observer)
+
+	limiter.Allow(ctx, "key")
+	limiter.Allow(ctx, "key")
+
+	suite.Equal([]bool{false, false}, observer.decisions)
+	suite.Equal(1, observer.storeCalls)
+	suite.Equal(1, observer.storeErrors)
+}
+
+func (suite *LimiterTestSuite) TestGivenLimitersWhenCallingAlgorithmThenReturnTheirAlgorithm() {
+	suite.Equal("fixed_window", NewLimiter(suite.store, 1, time.Second).Algorithm())
+	suite.Equal("token_bucket", NewTokenBucketLimiter(suite.store, 1, time.Second, 1).Algorithm())
+	suite.Equal("sliding_log", NewSlidingLogLimiter(suite.store, 1, time.Second).Algorithm())
+	suite.Equal("sliding_window", NewSlidingWindowLimiter(suite.store, 1, time.Second).Algorithm())
+	suite.Equal("gcra", NewGCRALimiter(suite.store, 1, time.Second, 1).Algorithm())
+	suite.Equal("leaky_bucket", NewLeakyBucketLimiter(suite.store, 1, time.Second, time.Second, 1).Algorithm())
+}
+
+func (suite *LimiterTestSuite) TestGivenRuleWhenCallingDecideThenReturnDecisionWithRuleAndQuota() {
+	key := "decide1"
+	limiter := NewLimiter(suite.store, 2, time.Minute)
+	suite.Equal("default", limiter.Rule())
+	limiter.SetRule("ip")
+
+	decision, err := limiter.Decide(ctx, key)
+	suite.NoError(err)
+	suite.True(decision.Allowed)
+	suite.Equal("ip", decision.Rule)
+	suite.Equal(2, decision.Limit)
+	suite.Equal(1, decision.Remaining)
+
+	limiter.Decide(ctx, key)
+	decision, err = limiter.Decide(ctx, key)
+	suite.NoError(err)
+	suite.False(decision.Allowed)
+	suite.Zero(decision.Remaining)
+	suite.InDelta(time.Minute, decision.RetryAfter, float64(time.Second))
+	suite.WithinDuration(time.Now().Add(time.Minute), decision.ResetAt, time.Second)
+}
+
+func (suite *LimiterTestSuite) TestGivenCostWhenCallingAllowNThenCostIsConsumedForEachAlgorithm() {
+	limiters := map[string]*Limiter{
+		"fixed":   NewLimiter(suite.store, 5, time.Minute),
+		"token":   NewTokenBucketLimiter(suite.store, 5, time.Minute, 5),
+		"log":     NewSlidingLogLimiter(suite.store, 5, time.Minute),
+		"sliding": NewSlidingWindowLimiter(suite.store, 5, time.Minute),
+		"gcra":    NewGCRALimiter(suite.store, 5, time.Minute, 5),
+		"leaky":   NewLeakyBucketLimiter(suite.store, 5, time.Minute, time.Hour, 4),
+	}
+	for name, limiter := range limiters {
+		key := "cost-" + name
+		allowed, quota, err := limiter.AllowN(ctx, key, 3)
+		suite.NoError(err, name)
+		suite.True(allowed, name)
+		suite.Equal(2, quota.Remaining, name)
+
+		allowed, quota, err = limiter.AllowN(ctx, key, 3)
+		suite.NoError(err, name)
+		suite.False(allowed, name)
+		suite.Positive(quota.RetryAfter, name)
+
+		allowed, _, err = limiter.AllowN(ctx, key, 2)
+		suite.NoError(err, name)
+		suite.True(allowed, name)
+		suite.True(shouldLimit(suite.T(), limiter, key), name)
+	}
+}
+
+// ttlStore is a Store that records the TTL each key is taken with.
+type ttlStore struct {
+	*memory.MemoryStore
+	ttls map[string]time.Duration
+}
+
+func (t *ttlStore) Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error) {
+	t.ttls[key] = ttl
+	return t.MemoryStore.Take(ctx, key, ttl, take)
+}
+
+func (suite *LimiterTestSuite) TestGivenStatusAlgorithmsWhenAllowingRequestsThenStatusesAreTakenWithTheirTTL() {
+	store := &ttlStore{MemoryStore: suite.store, ttls: make(map[string]time.Duration)}
+	NewLimiter(store, 10, time.Minute).Allow(ctx, "fixed")
+	NewSlidingWindowLimiter(store, 10, time.Minute).Allow(ctx, "sliding")
+	NewTokenBucketLimiter(store, 10, time.Minute, 5).Allow(ctx, "bucket")
+
+	suite.Equal(time.Minute, store.ttls["fixed"])
+	suite.Equal(2*time.Minute, store.ttls["sliding"])
+	suite.Equal(30*time.Second, store.ttls["bucket"])
+}
+
+// windowStore is a WindowStore that counts the fixed window decisions it takes.
+type windowStore struct {
+	*memory.MemoryStore
+	calls int
+}
+
+func (w *windowStore) TakeWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit, cost int) (*status.Status, bool, error) {
+	w.calls++
+	return w.Take(ctx, key, window, func(s *status.Status) bool {
+		if s.Count+cost > limit {
+			return false
+		}
+		s.Count += cost
+		return true
+	})
+}
+
+func (suite *LimiterTestSuite) TestGivenWindowStoreWhenAllowingRequestsThenFixedWindowDecisionIsLeftToTheStore() {
+	store := &windowStore{MemoryStore: suite.store}
+	limiter := NewLimiter(store, 1, time.Minute)
+
+	allowed, _, err := limiter.Allow(ctx, "key")
+	suite.NoError(err)
+	suite.True(allowed)
+	allowed, _, err = limiter.Allow(ctx, "key")
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(2, store.calls)
+}
@@ -0,0 +1,17 @@
+package limiter
+
+import "time"
+
+// Observer represents a sink for the decisions of a Limiter and the calls
+// it makes to its store, for example to export them as metrics.
+//
+// The methods are called synchronously on every request, so they must be
+// safe for concurrent use and should not block.
+type Observer interface {
+	// ObserveDecision is called with the rule of the limiter once a request
+	// is decided, including by the failure policy.
+	ObserveDecision(rule string, allowed bool)
+	// ObserveStore is called with the rule of the limiter after each call to
+	// the store, with how long it took and the error returned, if any.
+	ObserveStore(rule string, latency time.Duration, err error)
+}
@@ -0,0 +1,335 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Tier represents a plan with its own limit and duration, overriding the
+// ones of the limiters for the keys assigned to it.
+type Tier struct {
+	Name     string
+	Limit    int
+	Duration time.Duration
+}
+
+// Validate returns an error if the tier has no name, or its limit or
+// duration is not positive.
+func (t Tier) Validate() error {
+	if t.Name == "" {
+		return errors.New("tier without name")
+	}
+	if t.Limit <= 0 {
+		return fmt.Errorf("tier %q: limit must be positive", t.Name)
+	}
+	if t.Duration <= 0 {
+		return fmt.Errorf("tier %q: duration must be positive", t.Name)
+	}
+	return nil
+}
+
+// TierLookup looks up the tier of the keys not assigned to one in the
+// overrides, for example from a customer database.
+//
+// The keys are the ones of the limiters, which the policies prefix with the
+// name of the rule, as api_key:abc123 for the key abc123 of the api_key rule.
+// The results, errors included, are cached by the overrides for the lookup
+// TTL, so it is not called on every request.
+type TierLookup interface {
+	// LookupTier returns the name of the tier of a key and true,
+	// or false if the key has no tier.
+	LookupTier(ctx context.Context, key string) (string, bool, error)
+}
+
+// TierLookupFunc is a function used as a TierLookup.
+type TierLookupFunc func(ctx context.Context, key string) (string, bool, error)
+
+// LookupTier calls the function.
+func (f TierLookupFunc) LookupTier(ctx context.Context, key string) (string, bool, error) {
+	return f(ctx, key)
+}
+
+// defaultLookupTTL is how long the results of the tier lookup are cached
+// unless set with SetLookupTTL.
+const defaultLookupTTL = time.Minute
+
+// maxLookupCacheKeys is the most keys whose lookup results are cached, as
+// the keys come from the requests.
+const maxLookupCacheKeys = 10000
+
+// lookupResult represents the cached result of the lookup of a key.
+type lookupResult struct {
+	name      string
+	ok        bool
+	expiresAt time.Time
+}
+
+// overlay represents entries replaced as a whole when loaded, along with the
+// edits made over them one at a time, which are kept when they are replaced.
+type overlay[V any] struct {
+	loaded  map[string]V
+	set     map[string]V
+	removed map[string]bool
+}
+
+// newOverlay returns a new empty overlay.
+func newOverlay[V any]() overlay[V] {
+	return overlay[V]{
+		loaded:  make(map[string]V),
+		set:     make(map[string]V),
+		removed: make(map[string]bool),
+	}
+}
+
+// get returns the entry edited, or loaded if it was not edited.
+func (l *overlay[V]) get(name string) (V, bool) {
+	if value, ok := l.set[name]; ok {
+		return value, true
+	}
+	if l.removed[name] {
+		var zero V
+		return zero, false
+	}
+	value, ok := l.loaded[name]
+	return value, ok
+}
+
+// put edits an entry, adding or replacing it.
+func (l *overlay[V]) put(name string, value V) {
+	l.set[name] = value
+	delete(l.removed, name)
+}
+
+// remove edits an entry, removing it even if it is loaded again.
+func (l *overlay[V]) remove(name string) {
+	delete(l.set, name)
+	l.removed[name] = true
+}
+
+// all returns a copy of the entries, the edited ones over the loaded ones.
+func (l *overlay[V]) all() map[string]V {
+	values := make(map[string]V, len(l.loaded)+len(l.set))
+	for name, value := range l.loaded {
+		if !l.removed[name] {
+			values[name] = value
+		}
+	}
+	for name, value := range l.set {
+		values[name] = value
+	}
+	return values
+}
+
+// Overrides represents a registry of tiers and of the keys assigned to them,
+// which the limiters consult before applying their own limit and duration.
+//
+// The tiers and keys are loaded as a whole with Replace, as the policies do
+// on each reload, and edited at runtime with SetTier, RemoveTier, SetKey and
+// RemoveKey. The edits take precedence over the tiers and keys loaded and
+// are kept when they are replaced.
+//
+// The lookup errors are logged to slog.Default(), unless a logger is set
+// with SetLogger. It is safe to change while the limiters are in use.
+type Overrides struct {
+	tiers     overlay[Tier]
+	keys      overlay[string]
+	lookup    TierLookup
+	lookupTTL time.Duration
+	logger    *slog.Logger
+	mu        sync.RWMutex
+
+	lookups   map[string]lookupResult
+	lookupsMu sync.Mutex
+}
+
+// NewOverrides returns a new empty overrides registry.
+func NewOverrides() *Overrides {
+	return &Overrides{
+		tiers:     newOverlay[Tier](),
+		keys:      newOverlay[string](),
+		lookupTTL: defaultLookupTTL,
+		lookups:   make(map[string]lookupResult),
+	}
+}
+
+// SetTier adds or replaces a tier, or returns an error if it is invalid.
+//
+// The tier is kept when the tiers are replaced.
+func (o *Overrides) SetTier(tier Tier) error {
+	if err := tier.Validate(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tiers.put(tier.Name, tier)
+	return nil
+}
+
+// RemoveTier removes a tier, the keys assigned to it use the limiter defaults.
+//
+// The tier stays removed when the tiers are replaced, until it is set again.
+func (o *Overrides) RemoveTier(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tiers.remove(name)
+}
+
+// Tiers returns a copy of the tiers by name.
+func (o *Overrides) Tiers() map[string]Tier {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.tiers.all()
+}
+
+// SetKey assigns a key to a tier.
+//
+// The key stays assigned to the tier when the keys are replaced.
+func (o *Overrides) SetKey(key, tier string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.keys.put(key, tier)
+}
+
+// RemoveKey removes the tier assigned to a key.
+//
+// The key stays without a tier when the keys are replaced, until it is set again.
+func (o *Overrides) RemoveKey(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.keys.remove(key)
+}
+
+// Keys returns a copy of the tier names by key.
+func (o *Overrides) Keys() map[string]string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.keys.all()
+}
+
+// Replace replaces all the tiers and keys loaded, keeping the lookup and the
+// edits made at runtime, which still take precedence over them, or returns
+// an error, leaving them unchanged, if any tier is invalid.
+func (o *Overrides) Replace(tiers []Tier, keys map[string]string) error {
+	loadedTiers := make(map[string]Tier, len(tiers))
+	var errs []error
+	for _, tier := range tiers {
+		if err := tier.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		loadedTiers[tier.Name] = tier
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	loadedKeys := make(map[string]string, len(keys))
+	for key, tier := range keys {
+		loadedKeys[key] = tier
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tiers.loaded, o.keys.loaded = loadedTiers, loadedKeys
+	return nil
+}
+
+// SetLookup sets the lookup of the tier of the keys not assigned to one,
+// dropping the results cached from the previous one.
+func (o *Overrides) SetLookup(lookup TierLookup) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lookup = lookup
+	o.clearLookups()
+}
+
+// SetLookupTTL sets how long the results of the lookup are cached, one
+// minute by default, dropping the results cached. Zero or less disables
+// the cache.
+func (o *Overrides) SetLookupTTL(ttl time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lookupTTL = ttl
+	o.clearLookups()
+}
+
+// SetLogger sets the logger of the lookup errors, slog.Default() if nil.
+func (o *Overrides) SetLogger(logger *slog.Logger) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.logger = logger
+}
+
+// clearLookups drops the cached lookup results.
+func (o *Overrides) clearLookups() {
+	o.lookupsMu.Lock()
+	defer o.lookupsMu.Unlock()
+	clear(o.lookups)
+}
+
+// Tier returns the tier of a key and true, looking it up if the key is not
+// assigned to one, or false if the key has no tier.
+//
+// A key whose tier could not be looked up has no tier, the error is logged
+// once per lookup TTL, as it is cached along with the other results.
+func (o *Overrides) Tier(ctx context.Context, key string) (Tier, bool) {
+	o.mu.RLock()
+	name, ok := o.keys.get(key)
+	lookup, ttl, logger := o.lookup, o.lookupTTL, o.logger
+	o.mu.RUnlock()
+
+	if !ok && lookup != nil {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		name, ok = o.lookupTier(ctx, lookup, ttl, logger, key)
+	}
+	if !ok {
+		return Tier{}, false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.tiers.get(name)
+}
+
+// lookupTier returns the tier name of a key from the cache, or looks it up
+// and caches the result for ttl.
+func (o *Overrides) lookupTier(ctx context.Context, lookup TierLookup, ttl time.Duration, logger *slog.Logger, key string) (string, bool) {
+	now := time.Now()
+	o.lookupsMu.Lock()
+	result, cached := o.lookups[key]
+	o.lookupsMu.Unlock()
+	if cached && now.Before(result.expiresAt) {
+		return result.name, result.ok
+	}
+
+	name, ok, err := lookup.LookupTier(ctx, key)
+	if err != nil {
+		logger.ErrorContext(ctx, "error looking up the tier of a key", slog.String("key", key), slog.Any("error", err))
+		name, ok = "", false
+	}
+	if ttl <= 0 {
+		return name, ok
+	}
+	o.lookupsMu.Lock()
+	defer o.lookupsMu.Unlock()
+	if len(o.lookups) >= maxLookupCacheKeys {
+		o.dropLookups(now)
+	}
+	o.lookups[key] = lookupResult{name: name, ok: ok, expiresAt: now.Add(ttl)}
+	return name, ok
+}
+
+// dropLookups drops the expired lookup results, or all of them if none
+// expired, making room in the cache. It must be called with the lock held.
+func (o *Overrides) dropLookups(now time.Time) {
+	for key, result := range o.lookups {
+		if !now.Before(result.expiresAt) {
+			delete(o.lookups, key)
+		}
+	}
+	if len(o.lookups) >= maxLookupCacheKeys {
+		clear(o.lookups)
+	}
+}
@@ -0,0 +1,244 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type OverridesTestSuite struct {
+	suite.Suite
+	store     *memory.MemoryStore
+	overrides *Overrides
+}
+
+func (suite *OverridesTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+	suite.overrides = NewOverrides()
+	suite.Require().NoError(suite.overrides.SetTier(Tier{Name: "enterprise", Limit: 3, Duration: time.Minute}))
+}
+
+func TestOverridesSuite(t *testing.T) {
+	suite.Run(t, new(OverridesTestSuite))
+}
+
+func (suite *OverridesTestSuite) TestGivenKeyAssignedToTierWhenCallingAllowThenTierLimitIsUsed() {
+	limiter := NewLimiter(suite.store, 1, time.Second)
+	limiter.SetOverrides(suite.overrides)
+	suite.overrides.SetKey("abc123", "enterprise")
+
+	for range 3 {
+		allowed, quota, err := limiter.Allow(ctx, "abc123")
+		suite.NoError(err)
+		suite.True(allowed)
+		suite.Equal(3, quota.Limit)
+		suite.Equal(time.Minute, quota.Window)
+	}
+	suite.True(shouldLimit(suite.T(), limiter, "abc123"))
+
+	// Other keys keep the limiter defaults
+	suite.False(shouldLimit(suite.T(), limiter, "other"))
+	suite.True(shouldLimit(suite.T(), limiter, "other"))
+}
+
+func (suite *OverridesTestSuite) TestGivenKeyAssignedToTierWhenCallingKeyLimitThenReturnTierLimit() {
+	limiter := NewLimiter(suite.store, 1, time.Second)
+	limiter.SetOverrides(suite.overrides)
+	suite.overrides.SetKey("abc123", "enterprise")
+
+	limit, duration, tier := limiter.KeyLimit(ctx, "abc123")
+	suite.Equal(3, limit)
+	suite.Equal(time.Minute, duration)
+	suite.Equal("enterprise", tier)
+
+	limit, duration, tier = limiter.KeyLimit(ctx, "other")
+	suite.Equal(1, limit)
+	suite.Equal(time.Second, duration)
+	suite.Empty(tier)
+}
+
+func (suite *OverridesTestSuite) TestGivenKeyRemovedWhenCallingAllowThenDefaultLimitIsUsed() {
+	limiter := NewLimiter(suite.store, 1, time.Minute)
+	limiter.SetOverrides(suite.overrides)
+	suite.overrides.SetKey("abc123", "enterprise")
+	suite.False(shouldLimit(suite.T(), limiter, "abc123"))
+
+	suite.overrides.RemoveKey("abc123")
+	suite.True(shouldLimit(suite.T(), limiter, "abc123"))
+}
+
+func (suite *OverridesTestSuite) TestGivenLookupWhenKeyIsNotAssignedThenTierIsLookedUp() {
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		switch key {
+		case "customer":
+			return "enterprise", true, nil
+		case "broken":
+			return "", false, errors.New("database unavailable")
+		default:
+			return "", false, nil
+		}
+	}))
+
+	tier, ok := suite.overrides.Tier(ctx, "customer")
+	suite.True(ok)
+	suite.Equal("enterprise", tier.Name)
+	_, ok = suite.overrides.Tier(ctx, "broken")
+	suite.False(ok)
+	_, ok = suite.overrides.Tier(ctx, "unknown")
+	suite.False(ok)
+}
+
+func (suite *OverridesTestSuite) TestGivenLoggerWhenLookupFailsThenErrorIsLoggedToTheLogger() {
+	var buf bytes.Buffer
+	suite.overrides.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		return "", false, errors.New("database unavailable")
+	}))
+
+	_, ok := suite.overrides.Tier(ctx, "broken")
+	suite.False(ok)
+	suite.Contains(buf.String(), "error looking up the tier of a key")
+	suite.Contains(buf.String(), "database unavailable")
+}
+
+func (suite *OverridesTestSuite) TestGivenLookupWhenKeyIsCheckedAgainThenCachedResultIsUsed() {
+	calls := make(map[string]int)
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		calls[key]++
+		if key == "broken" {
+			return "", false, errors.New("database unavailable")
+		}
+		return "enterprise", true, nil
+	}))
+
+	for range 3 {
+		tier, ok := suite.overrides.Tier(ctx, "customer")
+		suite.True(ok)
+		suite.Equal("enterprise", tier.Name)
+		_, ok = suite.overrides.Tier(ctx, "broken")
+		suite.False(ok)
+	}
+	suite.Equal(map[string]int{"customer": 1, "broken": 1}, calls)
+}
+
+func (suite *OverridesTestSuite) TestGivenLookupTTLWhenItExpiresThenKeyIsLookedUpAgain() {
+	calls := 0
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		calls++
+		return "enterprise", true, nil
+	}))
+	suite.overrides.SetLookupTTL(10 * time.Millisecond)
+
+	suite.overrides.Tier(ctx, "customer")
+	suite.overrides.Tier(ctx, "customer")
+	suite.Equal(1, calls)
+	time.Sleep(20 * time.Millisecond)
+	suite.overrides.Tier(ctx, "customer")
+	suite.Equal(2, calls)
+
+	suite.overrides.SetLookupTTL(0)
+	suite.overrides.Tier(ctx, "customer")
+	suite.overrides.Tier(ctx, "customer")
+	suite.Equal(4, calls)
+}
+
+func (suite *OverridesTestSuite) TestGivenCachedLookupWhenLookupIsSetThenNewLookupIsUsed() {
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		return "", false, nil
+	}))
+	_, ok := suite.overrides.Tier(ctx, "customer")
+	suite.False(ok)
+
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		return "enterprise", true, nil
+	}))
+	_, ok = suite.overrides.Tier(ctx, "customer")
+	suite.True(ok)
+}
+
+func (suite *OverridesTestSuite) TestGivenLookupCacheFullWhenKeyIsLookedUpThenCacheStaysBounded() {
+	suite.overrides.SetLookup(TierLookupFunc(func(ctx context.Context, key string) (string, bool, error) {
+		return "", false, nil
+	}))
+	for i := range maxLookupCacheKeys + 1 {
+		suite.overrides.Tier(ctx, fmt.Sprintf("key%d", i))
+	}
+	suite.LessOrEqual(len(suite.overrides.lookups), maxLookupCacheKeys)
+	suite.Contains(suite.overrides.lookups, fmt.Sprintf("key%d", maxLookupCacheKeys))
+}
+
+func (suite *OverridesTestSuite) TestGivenKeyAssignedToMissingTierWhenCallingTierThenReturnFalse() {
+	suite.overrides.SetKey("abc123", "missing")
+	_, ok := suite.overrides.Tier(ctx, "abc123")
+	suite.False(ok)
+}
+
+func (suite *OverridesTestSuite) TestGivenLoadedOverridesWhenCallingReplaceThenLoadedTiersAndKeysAreReplaced() {
+	overrides := NewOverrides()
+	suite.NoError(overrides.Replace([]Tier{{Name: "enterprise", Limit: 3, Duration: time.Minute}}, map[string]string{"abc123": "enterprise"}))
+	err := overrides.Replace([]Tier{{Name: "pro", Limit: 2, Duration: time.Second}}, map[string]string{"def456": "pro"})
+
+	suite.NoError(err)
+	suite.Equal(map[string]Tier{"pro": {Name: "pro", Limit: 2, Duration: time.Second}}, overrides.Tiers())
+	suite.Equal(map[string]string{"def456": "pro"}, overrides.Keys())
+}
+
+func (suite *OverridesTestSuite) TestGivenRuntimeEditsWhenCallingReplaceThenEditsAreKeptOverTheLoadedOnes() {
+	suite.NoError(suite.overrides.Replace(
+		[]Tier{{Name: "enterprise", Limit: 5, Duration: time.Second}, {Name: "pro", Limit: 2, Duration: time.Second}},
+		map[string]string{"abc123": "pro", "def456": "pro", "ghi789": "pro"},
+	))
+	suite.overrides.SetKey("abc123", "enterprise")
+	suite.overrides.RemoveKey("def456")
+	suite.overrides.RemoveTier("pro")
+
+	// The policy is reloaded
+	suite.NoError(suite.overrides.Replace(
+		[]Tier{{Name: "enterprise", Limit: 5, Duration: time.Second}, {Name: "pro", Limit: 2, Duration: time.Second}},
+		map[string]string{"abc123": "pro", "def456": "pro", "ghi789": "pro"},
+	))
+
+	suite.Equal(map[string]Tier{"enterprise": {Name: "enterprise", Limit: 3, Duration: time.Minute}}, suite.overrides.Tiers())
+	suite.Equal(map[string]string{"abc123": "enterprise", "ghi789": "pro"}, suite.overrides.Keys())
+	tier, ok := suite.overrides.Tier(ctx, "abc123")
+	suite.True(ok)
+	suite.Equal(3, tier.Limit)
+	_, ok = suite.overrides.Tier(ctx, "def456")
+	suite.False(ok)
+	_, ok = suite.overrides.Tier(ctx, "ghi789")
+	suite.False(ok)
+
+	// Setting them again undoes the removals
+	suite.NoError(suite.overrides.SetTier(Tier{Name: "pro", Limit: 2, Duration: time.Second}))
+	suite.overrides.SetKey("def456", "pro")
+	_, ok = suite.overrides.Tier(ctx, "def456")
+	suite.True(ok)
+}
+
+func (suite *OverridesTestSuite) TestGivenTierWithoutLimitOrDurationWhenCallingSetTierThenReturnErrorAndTierIsNotSet() {
+	for _, tier := range []Tier{
+		{Name: "free", Limit: 0, Duration: time.Second},
+		{Name: "free", Limit: 1, Duration: 0},
+		{Name: "", Limit: 1, Duration: time.Second},
+	} {
+		suite.Error(suite.overrides.SetTier(tier))
+	}
+	suite.NotContains(suite.overrides.Tiers(), "free")
+	suite.NotContains(suite.overrides.Tiers(), "")
+}
+
+func (suite *OverridesTestSuite) TestGivenInvalidTierWhenCallingReplaceThenReturnErrorAndOverridesAreUnchanged() {
+	suite.overrides.SetKey("abc123", "enterprise")
+	err := suite.overrides.Replace([]Tier{{Name: "free", Limit: 0, Duration: time.Second}}, map[string]string{"def456": "free"})
+
+	suite.Error(err)
+	suite.Equal(map[string]Tier{"enterprise": {Name: "enterprise", Limit: 3, Duration: time.Minute}}, suite.overrides.Tiers())
+	suite.Equal(map[string]string{"abc123": "enterprise"}, suite.overrides.Keys())
+}
@@ -0,0 +1,37 @@
+package limiter
+
+import "time"
+
+// Quota represents the quota of a key after a request, as reported to clients.
+type Quota struct {
+	// Limit is the maximum number of requests allowed at once.
+	Limit int
+	// Remaining is the number of requests still allowed.
+	Remaining int
+	// ResetAt is when the quota is fully restored.
+	ResetAt time.Time
+	// RetryAfter is how long to wait before retrying a limited request.
+	RetryAfter time.Duration
+	// Delay is how long an allowed request waits in the queue before being handled,
+	// which is only positive for leaky bucket limiters.
+	Delay time.Duration
+	// Window is the duration of the limiter.
+	Window time.Duration
+}
+
+// tatRemaining returns the number of requests still allowed for a key spaced
+// by the interval, given its theoretical arrival time (TAT) after the request
+// and the tolerance ahead of now allowed for it.
+func tatRemaining(tat, now time.Time, interval, tolerance time.Duration) int {
+	headroom := tolerance - tat.Sub(now) + interval
+	if interval <= 0 || headroom < 0 {
+		return 0
+	}
+	return int(headroom / interval)
+}
+
+// blockedQuota returns the quota of a key of a limiter without a positive
+// limit, which limits every request as none fits in it.
+func blockedQuota(duration time.Duration) Quota {
+	return Quota{Limit: 0, Remaining: 0, ResetAt: time.Now().Add(duration), RetryAfter: duration}
+}
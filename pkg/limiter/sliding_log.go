@@ -0,0 +1,26 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// slidingLog keeps the time of each request allowed and limits the key once
+// there are limit requests in the rolling duration before the current one.
+type slidingLog struct {
+	store LogStore
+}
+
+func (s slidingLog) allow(ctx context.Context, _ Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	now := time.Now()
+	recorded, count, retryAfter, err := s.store.Record(ctx, key, now, duration, limit, cost)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	return recorded, Quota{
+		Limit:      limit,
+		Remaining:  max(limit-count, 0),
+		ResetAt:    now.Add(duration),
+		RetryAfter: retryAfter,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type SlidingLogTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *SlidingLogTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestSlidingLogSuite(t *testing.T) {
+	suite.Run(t, new(SlidingLogTestSuite))
+}
+
+func (suite *SlidingLogTestSuite) TestWhenCallingNewSlidingLogLimiterThenValuesAreSetup() {
+	limit := 5
+	duration := time.Minute
+	limiter := NewSlidingLogLimiter(suite.store, limit, duration)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(duration, limiter.duration)
+	suite.Equal(slidingLog{store: suite.store}, limiter.algorithm)
+}
+
+func (suite *SlidingLogTestSuite) TestGivenLimitNotReachedInWindowWhenCallingShouldLimitThenReturnFalse() {
+	key := "log1"
+	limiter := NewSlidingLogLimiter(suite.store, 2, time.Minute)
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+}
+
+func (suite *SlidingLogTestSuite) TestGivenLimitReachedInWindowWhenCallingShouldLimitThenReturnTrue() {
+	key := "log2"
+	limiter := NewSlidingLogLimiter(suite.store, 2, time.Minute)
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+}
+
+func (suite *SlidingLogTestSuite) TestGivenRequestsOutOfWindowWhenCallingShouldLimitThenReturnFalse() {
+	key := "log3"
+	suite.store.Record(ctx, key, time.Now().Add(-2*time.Minute), time.Minute, 1, 1)
+	limiter := NewSlidingLogLimiter(suite.store, 1, time.Minute)
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+}
+
+func (suite *SlidingLogTestSuite) TestGivenRequestsInWindowWhenCallingAllowThenRemainingIsCounted() {
+	key := "log4"
+	limiter := NewSlidingLogLimiter(suite.store, 3, time.Minute)
+	for i := range 3 {
+		allowed, quota, err := limiter.Allow(ctx, key)
+		suite.NoError(err)
+		suite.True(allowed)
+		suite.Equal(3, quota.Limit)
+		suite.Equal(2-i, quota.Remaining)
+	}
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Zero(quota.Remaining)
+	suite.InDelta(time.Minute, quota.RetryAfter, float64(time.Second))
+}
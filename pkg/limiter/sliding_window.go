@@ -0,0 +1,100 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// slidingWindow estimates the requests in the rolling duration from the
+// count of the current fixed window plus the count of the previous one,
+// weighted by how much of it still overlaps the rolling duration.
+//
+// The decision is left to the store if it is a SlidingWindowStore.
+type slidingWindow struct{}
+
+func (slidingWindow) allow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	now := time.Now()
+	s, allowed, err := takeSlidingWindow(ctx, store, key, limit, duration, cost, now)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	quota := Quota{
+		Limit:     limit,
+		Remaining: max(limit-int(math.Ceil(s.WeightedCount(duration))), 0),
+		ResetAt:   slidingResetAt(s, duration),
+	}
+	if !allowed {
+		quota.RetryAfter = slidingRetryAfter(s, limit-cost+1, duration, now)
+	}
+	return allowed, quota, nil
+}
+
+// takeSlidingWindow moves the status of a key to the fixed window containing
+// now and adds cost to its count if the weighted count stays below limit,
+// returning the status and true if it was added.
+func takeSlidingWindow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int, now time.Time) (*status.Status, bool, error) {
+	if w, ok := store.(SlidingWindowStore); ok {
+		return w.TakeSlidingWindow(ctx, key, now, duration, limit, cost)
+	}
+	// The counts are dropped once two durations have passed since it started
+	return store.Take(ctx, key, 2*duration, func(s *status.Status) bool {
+		*s = *slide(s, duration, now)
+		if s.WeightedCount(duration)+float64(cost-1) >= float64(limit) {
+			return false
+		}
+		s.Count += cost
+		return true
+	})
+}
+
+// slide returns a new status moved to the fixed window containing now.
+//
+// The count becomes the previous count when moving to the next window and
+// both counts are dropped when moving further than that.
+func slide(s *status.Status, duration time.Duration, now time.Time) *status.Status {
+	if duration <= 0 {
+		return &status.Status{Count: 0, StartedAt: now}
+	}
+	windows := now.Sub(s.StartedAt) / duration
+	switch {
+	case windows <= 0:
+		return &status.Status{Count: s.Count, StartedAt: s.StartedAt, PreviousCount: s.PreviousCount}
+	case windows == 1:
+		return &status.Status{Count: 0, StartedAt: s.StartedAt.Add(duration), PreviousCount: s.Count}
+	default:
+		return &status.Status{Count: 0, StartedAt: s.StartedAt.Add(windows * duration)}
+	}
+}
+
+// slidingRetryAfter returns how long until the weighted count of the status
+// drops below the limit, assuming no other request is allowed meanwhile.
+func slidingRetryAfter(s *status.Status, limit int, duration time.Duration, now time.Time) time.Duration {
+	if limit <= 0 || duration <= 0 {
+		return duration
+	}
+	// The previous count must weight less than what the current count leaves
+	start, count, previous := s.StartedAt, s.Count, s.PreviousCount
+	if count >= limit {
+		// The current count only becomes the previous count in the next window
+		start, count, previous = start.Add(duration), 0, count
+	}
+	weight := float64(limit-count) / float64(previous)
+	elapsed := time.Duration(float64(duration) * (1 - weight))
+	return max(start.Add(elapsed).Sub(now), 0)
+}
+
+// slidingResetAt returns when the weighted count of the status drops to zero,
+// assuming no other request is allowed meanwhile.
+func slidingResetAt(s *status.Status, duration time.Duration) time.Time {
+	switch {
+	case s.Count > 0:
+		return s.StartedAt.Add(2 * duration)
+	case s.PreviousCount > 0:
+		return s.StartedAt.Add(duration)
+	default:
+		return s.StartedAt
+	}
+}
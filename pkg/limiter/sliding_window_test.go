@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type SlidingWindowTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *SlidingWindowTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestSlidingWindowSuite(t *testing.T) {
+	suite.Run(t, new(SlidingWindowTestSuite))
+}
+
+func (suite *SlidingWindowTestSuite) TestWhenCallingNewSlidingWindowLimiterThenValuesAreSetup() {
+	limit := 5
+	duration := time.Minute
+	limiter := NewSlidingWindowLimiter(suite.store, limit, duration)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(duration, limiter.duration)
+	suite.Equal(slidingWindow{}, limiter.algorithm)
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenKeyDoesNotExistsWhenCallingShouldLimitThenReturnFalseUntilLimit() {
+	key := "window1"
+	limiter := NewSlidingWindowLimiter(suite.store, 2, time.Minute)
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(2, s.Count)
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenPreviousWindowReachedLimitWhenCallingShouldLimitThenItIsWeighted() {
+	key := "window2"
+	// The previous window reached the limit and a quarter of the current one has passed,
+	// so the previous window still weights 7.5 requests.
+	suite.store.Set(ctx, key, &status.Status{Count: 10, StartedAt: time.Now().Add(-75 * time.Second)})
+	limiter := NewSlidingWindowLimiter(suite.store, 10, time.Minute)
+
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(3, s.Count)
+	suite.Equal(10, s.PreviousCount)
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenPreviousWindowWhenCallingAllowThenRemainingIsWeighted() {
+	key := "window3"
+	startedAt := time.Now().Add(-75 * time.Second)
+	suite.store.Set(ctx, key, &status.Status{Count: 4, StartedAt: startedAt})
+	limiter := NewSlidingWindowLimiter(suite.store, 10, time.Minute)
+
+	// The previous window weights 3 requests, so the quota is 10 - ceil(3 + 1)
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.True(allowed)
+	suite.Equal(10, quota.Limit)
+	suite.Equal(6, quota.Remaining)
+	suite.Equal(startedAt.Add(3*time.Minute), quota.ResetAt)
+}
+
+// function slide
+
+func (suite *SlidingWindowTestSuite) TestGivenStatusInCurrentWindowWhenCallingSlideThenStatusIsKept() {
+	now := time.Now()
+	s := slide(&status.Status{Count: 3, StartedAt: now.Add(-time.Second), PreviousCount: 2}, time.Minute, now)
+	suite.Equal(&status.Status{Count: 3, StartedAt: now.Add(-time.Second), PreviousCount: 2}, s)
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenStatusInPreviousWindowWhenCallingSlideThenCountBecomesPreviousCount() {
+	now := time.Now()
+	s := slide(&status.Status{Count: 3, StartedAt: now.Add(-90 * time.Second), PreviousCount: 2}, time.Minute, now)
+	suite.Equal(&status.Status{Count: 0, StartedAt: now.Add(-30 * time.Second), PreviousCount: 3}, s)
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenStatusOlderThanPreviousWindowWhenCallingSlideThenCountsAreDropped() {
+	now := time.Now()
+	s := slide(&status.Status{Count: 3, StartedAt: now.Add(-150 * time.Second), PreviousCount: 2}, time.Minute, now)
+	suite.Equal(&status.Status{Count: 0, StartedAt: now.Add(-30 * time.Second)}, s)
+}
+
+// function slidingRetryAfter
+
+func (suite *SlidingWindowTestSuite) TestGivenPreviousCountReachedLimitWhenCallingSlidingRetryAfterThenReturnWhenItWeightsLess() {
+	now := time.Now()
+	s := &status.Status{Count: 5, StartedAt: now.Add(-15 * time.Second), PreviousCount: 10}
+	// The previous count must weight less than 5, which is half of the window
+	suite.Equal(15*time.Second, slidingRetryAfter(s, 10, time.Minute, now))
+}
+
+func (suite *SlidingWindowTestSuite) TestGivenCountReachedLimitWhenCallingSlidingRetryAfterThenReturnAfterNextWindowStarts() {
+	now := time.Now()
+	s := &status.Status{Count: 20, StartedAt: now.Add(-15 * time.Second)}
+	// The count becomes the previous count in 45 seconds and must weight less than 10
+	suite.Equal(75*time.Second, slidingRetryAfter(s, 10, time.Minute, now))
+}
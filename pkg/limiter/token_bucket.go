@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+)
+
+// tokenBucket allows up to burst requests at once and refills the bucket
+// at a rate of limit tokens per duration.
+//
+// The status count holds the tokens taken from the bucket and the status
+// started at holds the time from which the next token refill is measured.
+//
+// The decision is left to the store if it is a BucketStore.
+type tokenBucket struct {
+	burst int
+}
+
+func (t tokenBucket) allow(ctx context.Context, store Store, key string, limit int, duration time.Duration, cost int) (bool, Quota, error) {
+	// No request fits in a limit below 1, as in the fixed window
+	if limit < 1 {
+		return false, blockedQuota(duration), nil
+	}
+	interval := duration / time.Duration(limit)
+	s, allowed, err := t.take(ctx, store, key, interval, cost)
+	if err != nil {
+		return false, Quota{}, err
+	}
+	quota := Quota{
+		Limit:     t.burst,
+		Remaining: max(t.burst-s.Count, 0),
+		ResetAt:   s.StartedAt.Add(time.Duration(s.Count) * interval),
+	}
+	switch {
+	case !allowed && cost > t.burst:
+		// A cost above the burst never fits, as in the GCRA
+		quota.RetryAfter = duration
+	case !allowed:
+		quota.RetryAfter = time.Until(s.StartedAt.Add(time.Duration(s.Count+cost-t.burst) * interval))
+	}
+	return allowed, quota, nil
+}
+
+// take refills the bucket of a key and takes cost tokens from it if at most
+// burst tokens would be taken, returning the status and true if they were.
+func (t tokenBucket) take(ctx context.Context, store Store, key string, interval time.Duration, cost int) (*status.Status, bool, error) {
+	if b, ok := store.(BucketStore); ok {
+		return b.TakeBucket(ctx, key, time.Now(), interval, t.burst, cost)
+	}
+	// The bucket is full once the burst is refilled since it started
+	return store.Take(ctx, key, time.Duration(t.burst)*interval, func(s *status.Status) bool {
+		*s = *refill(s, interval, time.Now())
+		if s.Count+cost > t.burst {
+			return false
+		}
+		s.Count += cost
+		return true
+	})
+}
+
+// refill returns a new status with the tokens refilled since the status started.
+//
+// Only whole tokens are refilled, the remainder of the interval is kept in
+// the started at time so no refill time is lost between calls.
+func refill(s *status.Status, interval time.Duration, now time.Time) *status.Status {
+	if interval <= 0 {
+		return &status.Status{Count: 0, StartedAt: now}
+	}
+	tokens := max(int(now.Sub(s.StartedAt)/interval), 0)
+	if tokens >= s.Count {
+		return &status.Status{Count: 0, StartedAt: now}
+	}
+	return &status.Status{
+		Count:     s.Count - tokens,
+		StartedAt: s.StartedAt.Add(time.Duration(tokens) * interval),
+	}
+}
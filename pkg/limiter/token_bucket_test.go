@@ -0,0 +1,132 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokenBucketTestSuite struct {
+	suite.Suite
+	store *memory.MemoryStore
+}
+
+func (suite *TokenBucketTestSuite) SetupTest() {
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestTokenBucketSuite(t *testing.T) {
+	suite.Run(t, new(TokenBucketTestSuite))
+}
+
+func (suite *TokenBucketTestSuite) TestWhenCallingNewTokenBucketLimiterThenValuesAreSetup() {
+	limit := 5
+	duration := time.Second
+	burst := 10
+	limiter := NewTokenBucketLimiter(suite.store, limit, duration, burst)
+	suite.NotNil(limiter)
+	suite.Equal(suite.store, limiter.store)
+	suite.Equal(limit, limiter.limit)
+	suite.Equal(duration, limiter.duration)
+	suite.Equal(tokenBucket{burst: burst}, limiter.algorithm)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenFullBucketWhenCallingShouldLimitThenAllowBurstAndLimitAfter() {
+	key := "burst"
+	limiter := NewTokenBucketLimiter(suite.store, 1, time.Minute, 3)
+
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	suite.True(shouldLimit(suite.T(), limiter, key))
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(3, s.Count)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenEmptyBucketWhenIntervalPassesThenOneTokenIsRefilled() {
+	key := "refill"
+	startedAt := time.Now().Add(-1500 * time.Millisecond)
+	suite.store.Set(ctx, key, &status.Status{Count: 3, StartedAt: startedAt})
+	limiter := NewTokenBucketLimiter(suite.store, 1, time.Second, 3)
+
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(3, s.Count)
+	suite.Equal(startedAt.Add(time.Second), s.StartedAt)
+
+	suite.True(shouldLimit(suite.T(), limiter, key))
+}
+
+func (suite *TokenBucketTestSuite) TestGivenBucketWhenLongerThanRefillTimePassesThenBucketIsFull() {
+	key := "full"
+	suite.store.Set(ctx, key, &status.Status{Count: 3, StartedAt: time.Now().Add(-time.Hour)})
+	limiter := NewTokenBucketLimiter(suite.store, 1, time.Second, 3)
+
+	suite.False(shouldLimit(suite.T(), limiter, key))
+	s, err := limiter.GetStatus(ctx, key)
+	suite.NoError(err)
+	suite.Equal(1, s.Count)
+	suite.LessOrEqual(time.Since(s.StartedAt), time.Second)
+}
+
+// function refill
+
+func (suite *TokenBucketTestSuite) TestGivenStatusWhenCallingRefillThenOnlyWholeTokensAreRefilled() {
+	now := time.Now()
+	s := refill(&status.Status{Count: 5, StartedAt: now.Add(-250 * time.Millisecond)}, 100*time.Millisecond, now)
+	suite.Equal(3, s.Count)
+	suite.Equal(now.Add(-50*time.Millisecond), s.StartedAt)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenStatusStartedInTheFutureWhenCallingRefillThenNoTokenIsRefilled() {
+	now := time.Now()
+	s := refill(&status.Status{Count: 2, StartedAt: now.Add(time.Second)}, 100*time.Millisecond, now)
+	suite.Equal(2, s.Count)
+	suite.Equal(now.Add(time.Second), s.StartedAt)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenEmptyBucketWhenCallingAllowThenReturnRetryAfterNextToken() {
+	key := "retry"
+	startedAt := time.Now().Add(-300 * time.Millisecond)
+	suite.store.Set(ctx, key, &status.Status{Count: 3, StartedAt: startedAt})
+	limiter := NewTokenBucketLimiter(suite.store, 1, time.Second, 3)
+
+	allowed, quota, err := limiter.Allow(ctx, key)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.InDelta(700*time.Millisecond, quota.RetryAfter, float64(10*time.Millisecond))
+	suite.Equal(3, quota.Limit)
+	suite.Zero(quota.Remaining)
+	suite.Equal(startedAt.Add(3*time.Second), quota.ResetAt)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenCostAboveBurstWhenCallingAllowNThenReturnRetryAfterDuration() {
+	limiter := NewTokenBucketLimiter(suite.store, 1, time.Minute, 2)
+
+	allowed, quota, err := limiter.AllowN(ctx, "bucket-cost", 3)
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(time.Minute, quota.RetryAfter)
+	suite.Equal(2, quota.Remaining)
+}
+
+func (suite *TokenBucketTestSuite) TestGivenZeroLimitWhenCallingAllowThenRequestIsLimitedWithoutPanicking() {
+	limiter := NewTokenBucketLimiter(suite.store, 0, time.Second, 5)
+
+	allowed, quota, err := limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+	suite.Equal(0, quota.Remaining)
+	suite.Equal(time.Second, quota.RetryAfter)
+
+	limiter = NewTokenBucketLimiter(suite.store, 1, time.Second, 5)
+	limiter.SetLimit(0)
+	allowed, _, err = limiter.Allow(ctx, "zero")
+	suite.NoError(err)
+	suite.False(allowed)
+}
@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the spans of the limiters.
+const tracerName = "github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+
+// tracer returns the tracer of the tracer provider, or of the global one if nil.
+func tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// keyHashSalt is the random secret of the key hashes, drawn once per process
+// so the hashes of low entropy keys, as IPs, cannot be reversed by hashing
+// every candidate.
+var keyHashSalt = newKeyHashSalt()
+
+// newKeyHashSalt returns a new random secret for the key hashes.
+func newKeyHashSalt() []byte {
+	salt := make([]byte, sha256.Size)
+	if _, err := rand.Read(salt); err != nil {
+		panic("limiter: reading the key hash salt: " + err.Error())
+	}
+	return salt
+}
+
+// hashKey returns a short HMAC of a key, so the keys, which may be IPs or
+// API keys, are not exported as they are. The hashes of a key are the same
+// within a process, but differ between processes.
+func hashKey(key string) string {
+	mac := hmac.New(sha256.New, keyHashSalt)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// endSpan sets the attributes of a decision on the span of a check and ends it.
+func endSpan(span trace.Span, allowed bool, quota Quota, tier string, storeLatency time.Duration, err error) {
+	decision := "limited"
+	if allowed {
+		decision = "allowed"
+	}
+	span.SetAttributes(
+		attribute.String("rate_limiter.decision", decision),
+		attribute.Int("rate_limiter.limit", quota.Limit),
+		attribute.Int("rate_limiter.remaining", quota.Remaining),
+		attribute.Float64("rate_limiter.store_latency_ms", float64(storeLatency.Microseconds())/1000),
+	)
+	if tier != "" {
+		span.SetAttributes(attribute.String("rate_limiter.tier", tier))
+	}
+	if quota.Delay > 0 {
+		span.SetAttributes(attribute.Float64("rate_limiter.delay_ms", float64(quota.Delay.Microseconds())/1000))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
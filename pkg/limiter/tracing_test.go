@@ -0,0 +1,62 @@
+package limiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func (suite *LimiterTestSuite) TestGivenTracerProviderWhenCheckingRequestsThenSpansCarryTheDecision() {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	limiter := NewLimiter(suite.store, 1, time.Minute)
+	limiter.SetRule("ip")
+	limiter.SetTracerProvider(provider)
+
+	parentCtx, parent := provider.Tracer("test").Start(ctx, "request")
+	limiter.Allow(parentCtx, "ip:10.0.0.1")
+	limiter.Allow(parentCtx, "ip:10.0.0.1")
+	parent.End()
+
+	spans := recorder.Ended()
+	suite.Require().Len(spans, 3)
+	for i, decision := range []string{"allowed", "limited"} {
+		span := spans[i]
+		suite.Equal("rate_limiter.check", span.Name())
+		suite.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+		attributes := span.Attributes()
+		suite.Contains(attributes, attribute.String("rate_limiter.rule", "ip"))
+		suite.Contains(attributes, attribute.String("rate_limiter.key_hash", hashKey("ip:10.0.0.1")))
+		suite.Contains(attributes, attribute.String("rate_limiter.decision", decision))
+		suite.Contains(attributes, attribute.Int("rate_limiter.limit", 1))
+		suite.Contains(attributes, attribute.Int("rate_limiter.remaining", 0))
+	}
+	suite.NotContains(spans[0].Attributes(), attribute.String("rate_limiter.key_hash", "ip:10.0.0.1"))
+}
+
+func (suite *LimiterTestSuite) TestGivenTracerProviderWhenStoreFailsThenSpanRecordsTheError() {
+	recorder := tracetest.NewSpanRecorder()
+	limiter := NewLimiter(failingStore{}, 1, time.Minute)
+	limiter.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	limiter.Allow(ctx, "key")
+
+	spans := recorder.Ended()
+	suite.Require().Len(spans, 1)
+	suite.Equal(codes.Error, spans[0].Status().Code)
+	suite.Equal("store unavailable", spans[0].Status().Description)
+	suite.Contains(spans[0].Attributes(), attribute.String("rate_limiter.decision", "allowed"))
+}
+
+func (suite *LimiterTestSuite) TestGivenKeyWhenCallingHashKeyThenHashIsSaltedAndStable() {
+	sum := sha256.Sum256([]byte("ip:10.0.0.1"))
+	suite.Equal(hashKey("ip:10.0.0.1"), hashKey("ip:10.0.0.1"))
+	suite.NotEqual(hex.EncodeToString(sum[:8]), hashKey("ip:10.0.0.1"))
+	suite.NotEqual(hashKey("ip:10.0.0.1"), hashKey("ip:10.0.0.2"))
+	suite.Len(hashKey("ip:10.0.0.1"), 16)
+}
@@ -0,0 +1,84 @@
+// Package metrics exports the decisions of the rate limiters and the calls
+// they make to their stores as Prometheus metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "rate_limiter"
+
+// KeyCounter represents a store that can report how many keys it holds.
+type KeyCounter interface {
+	Len() int
+}
+
+// Metrics represents the Prometheus metrics of a set of rate limiters.
+//
+// It implements limiter.Observer, so it is set on each limiter with SetObserver.
+type Metrics struct {
+	registerer   prometheus.Registerer
+	decisions    *prometheus.CounterVec
+	storeLatency *prometheus.HistogramVec
+	storeErrors  *prometheus.CounterVec
+}
+
+// NewMetrics returns new metrics registered in the registerer.
+//
+// It panics if the metrics were already registered in it.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		registerer: registerer,
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Number of requests decided by the rate limiters, per rule and decision (allowed or limited).",
+		}, []string{"rule", "decision"}),
+		storeLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_duration_seconds",
+			Help:      "Duration of the calls made by the rate limiters to their store, per rule.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"rule"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_errors_total",
+			Help:      "Number of calls made by the rate limiters to their store that failed, per rule.",
+		}, []string{"rule"}),
+	}
+	registerer.MustRegister(m.decisions, m.storeLatency, m.storeErrors)
+	return m
+}
+
+// RegisterKeyCount registers a gauge with the number of keys held by the store.
+//
+// It panics if a key count was already registered.
+func (m *Metrics) RegisterKeyCount(store KeyCounter) {
+	m.registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "store_keys",
+		Help:      "Number of keys held by the rate limiters store.",
+	}, func() float64 {
+		return float64(store.Len())
+	}))
+}
+
+// ObserveDecision counts a request decided by the limiter of the rule.
+func (m *Metrics) ObserveDecision(rule string, allowed bool) {
+	decision := "limited"
+	if allowed {
+		decision = "allowed"
+	}
+	m.decisions.WithLabelValues(rule, decision).Inc()
+}
+
+// ObserveStore records the latency of a call to the store made by the limiter
+// of the rule, counting it as an error if it failed.
+func (m *Metrics) ObserveStore(rule string, latency time.Duration, err error) {
+	m.storeLatency.WithLabelValues(rule).Observe(latency.Seconds())
+	if err != nil {
+		m.storeErrors.WithLabelValues(rule).Inc()
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/stretchr/testify/suite"
+)
+
+var ctx = context.Background()
+
+type MetricsTestSuite struct {
+	suite.Suite
+	registry *prometheus.Registry
+	metrics  *Metrics
+	store    *memory.MemoryStore
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	suite.registry = prometheus.NewRegistry()
+	suite.metrics = NewMetrics(suite.registry)
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (suite *MetricsTestSuite) TestGivenObservedLimiterWhenDecidingRequestsThenDecisionsAreCountedPerRule() {
+	l := limiter.NewLimiter(suite.store, 2, time.Minute)
+	l.SetRule("ip")
+	l.SetObserver(suite.metrics)
+
+	for range 3 {
+		l.Allow(ctx, "ip:10.0.0.1")
+	}
+
+	suite.Equal(2.0, testutil.ToFloat64(suite.metrics.decisions.WithLabelValues("ip", "allowed")))
+	suite.Equal(1.0, testutil.ToFloat64(suite.metrics.decisions.WithLabelValues("ip", "limited")))
+	suite.Equal(1, testutil.CollectAndCount(suite.metrics.storeLatency))
+	suite.Equal(0, testutil.CollectAndCount(suite.metrics.storeErrors))
+}
+
+func (suite *MetricsTestSuite) TestGivenStoreErrorWhenObservingStoreThenErrorIsCounted() {
+	suite.metrics.ObserveStore("ip", time.Millisecond, nil)
+	suite.metrics.ObserveStore("ip", time.Millisecond, errors.New("unreachable"))
+
+	suite.Equal(1.0, testutil.ToFloat64(suite.metrics.storeErrors.WithLabelValues("ip")))
+}
+
+func (suite *MetricsTestSuite) TestGivenKeyCountRegisteredWhenGatheringThenReportsStoreKeys() {
+	suite.metrics.RegisterKeyCount(suite.store)
+	suite.store.Increment(ctx, "ip:10.0.0.1")
+	suite.store.Increment(ctx, "ip:10.0.0.2")
+
+	expected := `
+# HELP rate_limiter_store_keys Number of keys held by the rate limiters store.
+# TYPE rate_limiter_store_keys gauge
+rate_limiter_store_keys 2
+`
+	err := testutil.GatherAndCompare(suite.registry, strings.NewReader(expected), "rate_limiter_store_keys")
+	suite.NoError(err)
+}
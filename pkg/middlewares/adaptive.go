@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+)
+
+// NewAdaptiveMiddleware returns a middleware that reports the latency and the
+// server errors (5xx status codes) of the next handler to the limiter.AIMD
+// controller, which adjusts the limit of its limiter.
+//
+// It should be placed after the rate limiter middlewares in the chain,
+// so limited requests are not observed.
+func NewAdaptiveMiddleware(a *limiter.AIMD) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := newResponseRecorder(w)
+			start := time.Now()
+			next.ServeHTTP(recorder, r)
+			a.Observe(time.Since(start), recorder.status >= http.StatusInternalServerError)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdaptiveMiddlewareTestSuite struct {
+	suite.Suite
+	limiter *limiter.Limiter
+}
+
+func (suite *AdaptiveMiddlewareTestSuite) SetupTest() {
+	suite.limiter = limiter.NewLimiter(memory.NewMemoryStore(), 10, 0)
+}
+
+func TestAdaptiveMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(AdaptiveMiddlewareTestSuite))
+}
+
+func (suite *AdaptiveMiddlewareTestSuite) TestGivenMiddlewareWhenHandlerSucceedsThenLimitIsIncreased() {
+	middleware := NewAdaptiveMiddleware(limiter.NewAIMD(suite.limiter, 1, 20, time.Second, 0.1))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	rec := httptest.NewRecorder()
+	middleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(11, suite.limiter.Limit())
+}
+
+func (suite *AdaptiveMiddlewareTestSuite) TestGivenMiddlewareWhenHandlerFailsThenLimitIsDecreased() {
+	middleware := NewAdaptiveMiddleware(limiter.NewAIMD(suite.limiter, 1, 20, time.Second, 0.1))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	rec := httptest.NewRecorder()
+	middleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	suite.Equal(http.StatusBadGateway, rec.Code)
+	suite.Equal(9, suite.limiter.Limit())
+}
+
+func (suite *AdaptiveMiddlewareTestSuite) TestGivenMiddlewareWhenHandlerIsSlowThenLimitIsDecreased() {
+	middleware := NewAdaptiveMiddleware(limiter.NewAIMD(suite.limiter, 1, 20, time.Millisecond, 0.1))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	rec := httptest.NewRecorder()
+	middleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	suite.Equal(9, suite.limiter.Limit())
+}
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+)
+
+// NewConcurrencyLimiterMiddleware returns a middleware that limits the number of in-flight requests per key.
+//
+// It uses the provided limiter.ConcurrencyLimiter to take a slot for the request,
+// which is released once the next handler returns.
+//
+// The keyMapper function is used to extract the key from the request.
+// If the keyMapper function is nil, the defaultKeyMapper function is used.
+//
+// If the limiter store could not be reached, the error is logged and the request is allowed.
+// The middleware logs to slog.Default(), unless a logger is given with WithLogger,
+// the other options do not apply to it.
+func NewConcurrencyLimiterMiddleware(l *limiter.ConcurrencyLimiter, keyMapper func(*http.Request) string, opts ...Option) func(http.Handler) http.Handler {
+	logger := newOptions(opts).log()
+	if keyMapper == nil {
+		keyMapper = defaultKeyMapper(logger)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyMapper(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			attrs := []slog.Attr{
+				slog.String("request_id", requestID(r)),
+				slog.String("key", key),
+			}
+			release, ok, err := l.Acquire(r.Context(), key)
+			if err != nil {
+				logger.LogAttrs(r.Context(), slog.LevelError, "concurrency limiter store error", append(
+					attrs,
+					slog.String("decision", decisionName(true)),
+					slog.Any("error", err),
+				)...)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ok {
+				logger.LogAttrs(r.Context(), slog.LevelInfo, "request concurrency limited", append(
+					attrs,
+					slog.String("decision", decisionName(false)),
+					slog.Int("status", http.StatusTooManyRequests),
+				)...)
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"message": "you have reached the maximum number of requests in progress at the same time"}`))
+				return
+			}
+			defer func() {
+				if err := release(context.WithoutCancel(r.Context())); err != nil {
+					logger.LogAttrs(r.Context(), slog.LevelError, "concurrency limiter store error", append(
+						attrs,
+						slog.Any("error", err),
+					)...)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
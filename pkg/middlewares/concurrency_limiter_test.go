@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConcurrencyLimiterMiddlewareTestSuite struct {
+	suite.Suite
+	middleware func(http.Handler) http.Handler
+}
+
+func (suite *ConcurrencyLimiterMiddlewareTestSuite) SetupTest() {
+	store := memory.NewMemoryStore()
+	limiter := limiter.NewConcurrencyLimiter(store, 1, time.Minute)
+	suite.middleware = NewConcurrencyLimiterMiddleware(limiter, nil)
+}
+
+func TestConcurrencyLimiterMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(ConcurrencyLimiterMiddlewareTestSuite))
+}
+
+func (suite *ConcurrencyLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenRequestIsInFlightThenOtherRequestShouldReturnStatusTooManyRequests() {
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.1:12345"
+
+	inFlight := make(chan struct{})
+	done := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "" {
+			close(inFlight)
+			<-done
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	waitReq := httptest.NewRequest(http.MethodGet, "/?wait=1", nil)
+	waitReq.RemoteAddr = req1.RemoteAddr
+	rec1 := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		suite.middleware(handler).ServeHTTP(rec1, waitReq)
+		close(finished)
+	}()
+	<-inFlight
+
+	// Second request is limited while the first is in flight
+	rec2 := httptest.NewRecorder()
+	suite.middleware(handler).ServeHTTP(rec2, req1)
+	suite.Equal(http.StatusTooManyRequests, rec2.Code)
+
+	// Third request is allowed once the first has finished
+	close(done)
+	<-finished
+	suite.Equal(http.StatusOK, rec1.Code)
+	rec3 := httptest.NewRecorder()
+	suite.middleware(handler).ServeHTTP(rec3, req1)
+	suite.Equal(http.StatusOK, rec3.Code)
+}
+
+func (suite *ConcurrencyLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenKeyIsEmptyThenShouldReturnStatusOk() {
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = ""
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec1 := httptest.NewRecorder()
+	suite.middleware(handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+}
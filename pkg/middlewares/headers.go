@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+)
+
+const rateLimitDecisionKey contextKey = "rateLimitDecision"
+
+// decisionHolder holds the most restrictive decision made on a request by the
+// rate limiter middlewares in the chain.
+type decisionHolder struct {
+	decision limiter.Decision
+	set      bool
+}
+
+// withDecisionHolder returns the request with a decisionHolder in its context,
+// reusing the one of a previous middleware in the chain.
+func withDecisionHolder(r *http.Request) (*http.Request, *decisionHolder) {
+	if holder, ok := r.Context().Value(rateLimitDecisionKey).(*decisionHolder); ok {
+		return r, holder
+	}
+	holder := &decisionHolder{}
+	return r.WithContext(context.WithValue(r.Context(), rateLimitDecisionKey, holder)), holder
+}
+
+// update keeps the decision if it has less remaining requests than the one held,
+// or if it limited the request.
+func (h *decisionHolder) update(decision limiter.Decision) {
+	if !h.set || !decision.Allowed || decision.Remaining < h.decision.Remaining {
+		h.decision = decision
+		h.set = true
+	}
+}
+
+// writeRateLimitHeaders writes the headers of the decision to the response,
+// along with the Retry-After header when the request was limited.
+func writeRateLimitHeaders(w http.ResponseWriter, decision limiter.Decision, o options) {
+	h := w.Header()
+	reset := ceilSeconds(time.Until(decision.ResetAt))
+	h.Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+reset, 10))
+	if !decision.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(ceilSeconds(decision.RetryAfter), 10))
+	} else {
+		h.Del("Retry-After")
+	}
+	if o.ietfHeaders {
+		h.Set("RateLimit-Policy", fmt.Sprintf(`%q;q=%d;w=%d`, decision.Rule, decision.Limit, ceilSeconds(decision.Window)))
+		h.Set("RateLimit", fmt.Sprintf(`%q;r=%d;t=%d`, decision.Rule, decision.Remaining, reset))
+	}
+}
+
+// ceilSeconds returns the duration in whole seconds rounded up, or zero if
+// it is not positive.
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -2,35 +2,60 @@ package middlewares
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const uidKey contextKey = "uid"
 
-// LogRequest logs the request method, path and IP address
-// of the request to the standard output.
+// requestID returns the id given to the request by the log request middleware,
+// or an empty string if it has none.
+func requestID(r *http.Request) string {
+	uid, _ := r.Context().Value(uidKey).(string)
+	return uid
+}
+
+// LogRequest logs the start and end of each request to slog.Default(),
+// as NewLogRequestMiddleware does.
 func LogRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		uid := uuid.New().String()
-		r = r.WithContext(context.WithValue(r.Context(), uidKey, uid))
+	return NewLogRequestMiddleware(nil)(next)
+}
+
+// NewLogRequestMiddleware returns a middleware that gives each request an id,
+// which the other middlewares log as request_id, and logs the start and end
+// of each request to the logger, or slog.Default() if nil.
+//
+// The requests are logged at the debug level, with the method, path and
+// IP address, and once it ends its status code and duration.
+func NewLogRequestMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logger
+			if log == nil {
+				log = slog.Default()
+			}
+			uid := uuid.New().String()
+			r = r.WithContext(context.WithValue(r.Context(), uidKey, uid))
+			log = log.With(
+				slog.String("request_id", uid),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("ip", r.RemoteAddr),
+			)
 
-		log.Printf(
-			"%s | START | %s %s | IP %s",
-			uid,
-			r.Method,
-			r.URL.Path,
-			r.RemoteAddr,
-		)
-		next.ServeHTTP(w, r)
-		log.Printf(
-			"%s | END | %s %s | IP %s",
-			uid,
-			r.Method,
-			r.URL.Path,
-			r.RemoteAddr,
-		)
-	})
+			start := time.Now()
+			log.DebugContext(r.Context(), "request started")
+			recorder := newResponseRecorder(w)
+			next.ServeHTTP(recorder, r)
+			log.DebugContext(
+				r.Context(),
+				"request ended",
+				slog.Int("status", recorder.status),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
 }
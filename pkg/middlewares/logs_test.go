@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LogRequestMiddlewareTestSuite struct {
+	suite.Suite
+	handler http.Handler
+}
+
+func (suite *LogRequestMiddlewareTestSuite) SetupTest() {
+	suite.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+func TestLogRequestMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(LogRequestMiddlewareTestSuite))
+}
+
+// logLines returns the JSON log lines written to the buffer.
+func logLines(buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]any
+		json.Unmarshal([]byte(line), &fields)
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func (suite *LogRequestMiddlewareTestSuite) TestGivenDebugLevelWhenRequestIsExecutedThenStartAndEndAreLogged() {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.RemoteAddr = "192.168.0.1:12345"
+	NewLogRequestMiddleware(logger)(suite.handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(&buf)
+	suite.Require().Len(lines, 2)
+	suite.Equal("request started", lines[0]["msg"])
+	suite.Equal("request ended", lines[1]["msg"])
+	suite.NotEmpty(lines[0]["request_id"])
+	suite.Equal(lines[0]["request_id"], lines[1]["request_id"])
+	suite.Equal("GET", lines[1]["method"])
+	suite.Equal("/hello", lines[1]["path"])
+	suite.Equal(float64(http.StatusCreated), lines[1]["status"])
+	suite.Contains(lines[1], "duration")
+}
+
+func (suite *LogRequestMiddlewareTestSuite) TestGivenInfoLevelWhenRequestIsExecutedThenNothingIsLogged() {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	rec := httptest.NewRecorder()
+	NewLogRequestMiddleware(logger)(suite.handler).ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusCreated, rec.Code)
+	suite.Empty(buf.String())
+}
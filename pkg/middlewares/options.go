@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+)
+
+// Option configures the rate limiter middlewares.
+type Option func(*options)
+
+type options struct {
+	ietfHeaders bool
+	cost        func(*http.Request) int
+	logger      *slog.Logger
+	policy      *limiter.FailurePolicy
+}
+
+// newOptions returns the options configured by opts.
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithIETFHeaders makes the middleware also write the IETF RateLimit and
+// RateLimit-Policy header fields besides the X-RateLimit headers.
+func WithIETFHeaders() Option {
+	return func(o *options) {
+		o.ietfHeaders = true
+	}
+}
+
+// WithCost makes each request consume the units of the limit returned by
+// the cost function, for example by route, method or body size.
+//
+// Without it each request consumes one unit, as do costs below one.
+func WithCost(cost func(*http.Request) int) Option {
+	return func(o *options) {
+		o.cost = cost
+	}
+}
+
+// WithLogger makes the middleware log to the logger instead of slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithFailurePolicy makes the middleware decide the requests by the policy
+// when the limiter store could not be reached, instead of by the failure
+// policy of the limiter.
+func WithFailurePolicy(policy limiter.FailurePolicy) Option {
+	return func(o *options) {
+		o.policy = &policy
+	}
+}
+
+// Logger returns the logger configured by the options, slog.Default() if
+// none, so the components built along the middlewares log to it as well.
+func Logger(opts ...Option) *slog.Logger {
+	return newOptions(opts).log()
+}
+
+// costOf returns the units of the limit consumed by the request.
+func (o options) costOf(r *http.Request) int {
+	if o.cost == nil {
+		return 1
+	}
+	return max(o.cost(r), 1)
+}
+
+// allowedOnFailure returns whether a request the limiter decided while its
+// store could not be reached is allowed.
+func (o options) allowedOnFailure(allowed bool) bool {
+	if o.policy == nil {
+		return allowed
+	}
+	return *o.policy == limiter.FailOpen
+}
+
+// log returns the logger of the middleware.
+func (o options) log() *slog.Logger {
+	if o.logger == nil {
+		return slog.Default()
+	}
+	return o.logger
+}
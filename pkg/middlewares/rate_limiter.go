@@ -2,10 +2,12 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
 )
@@ -14,17 +16,27 @@ type contextKey string
 
 const rateLimitAllowedKey contextKey = "rateLimitAllowed"
 
-// defaultKeyMapper returns the IP address of the request as the key for the rate limiter.
+// defaultKeyMapper returns a key mapper returning the IP address of the request
+// as the key for the rate limiter.
 //
 // It is used when no keyMapper function is provided to the NewRateLimiterMiddleware function.
-// It returns an empty string if it fails to parse the IP address.
-func defaultKeyMapper(r *http.Request) string {
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		log.Printf("error parsing IP from RemoteAddr %s: %s\n", r.RemoteAddr, err)
-		return ""
+// The key mapper returns an empty string if it fails to parse the IP address,
+// logging the error to the logger.
+func defaultKeyMapper(logger *slog.Logger) func(*http.Request) string {
+	return func(r *http.Request) string {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			logger.WarnContext(
+				r.Context(),
+				"error parsing IP from RemoteAddr",
+				slog.String("request_id", requestID(r)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.Any("error", err),
+			)
+			return ""
+		}
+		return fmt.Sprintf("IP:%s", ip)
 	}
-	return fmt.Sprintf("IP:%s", ip)
 }
 
 // NewRateLimiterMiddleware returns a middleware that limits the number of requests per key.
@@ -37,25 +49,106 @@ func defaultKeyMapper(r *http.Request) string {
 // The middleware adds a context value "rateLimitAllowed" to the request context to avoid
 // checking the rate limit for the same request multiple times, which allow for multiple rate limiters
 // to be used in the same middleware chain.
-func NewRateLimiterMiddleware(l *limiter.Limiter, keyMapper func(*http.Request) string) func(http.Handler) http.Handler {
+//
+// When the limiter queues requests, as leaky bucket limiters do, the middleware holds the request
+// until its turn, and responds with a service unavailable status if the request is canceled meanwhile.
+//
+// If the limiter store could not be reached, the error is logged and the request is decided by the
+// failure policy of the limiter, or the one given with WithFailurePolicy, responding with a service
+// unavailable status when it fails closed. While the circuit breaker of the limiter is open the
+// requests are logged at the debug level, as the breaker already logs when it opens and closes.
+//
+// Every response decided by the limiter has the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers, and limited responses the Retry-After header. With several rate limiter
+// middlewares in the chain, the headers report the most restrictive limiter checked, including the
+// ones checked after a limiter let the request through because its store could not be reached.
+// The logs and headers identify the limiters by their rule.
+// The IETF RateLimit and RateLimit-Policy headers are also written when using WithIETFHeaders.
+//
+// Each request consumes one unit of the limit, unless a cost function is given with WithCost.
+// The middleware logs to slog.Default(), unless a logger is given with WithLogger.
+func NewRateLimiterMiddleware(l *limiter.Limiter, keyMapper func(*http.Request) string, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+	logger := o.log()
 	if keyMapper == nil {
-		keyMapper = defaultKeyMapper
+		keyMapper = defaultKeyMapper(logger)
 	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The holder is shared by every limiter checking the request
+			r, holder := withDecisionHolder(r)
 			key := keyMapper(r)
 			if key == "" {
 				next.ServeHTTP(w, r)
 				return
 			}
-			if r.Context().Value(rateLimitAllowedKey) != true && l.ShouldLimit(key) {
-				log.Printf("%s | LIMITED | %s", r.Context().Value(uidKey), key)
-				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"message": "you have reached the maximum number of requests or actions allowed within a certain time frame"}`))
-				return
+			if r.Context().Value(rateLimitAllowedKey) != true {
+				decision, err := l.DecideN(r.Context(), key, o.costOf(r))
+				attrs := []slog.Attr{
+					slog.String("request_id", requestID(r)),
+					slog.String("key", key),
+					slog.String("rule", decision.Rule),
+				}
+				if err != nil && r.Context().Err() != nil {
+					logger.LogAttrs(r.Context(), slog.LevelWarn, "request canceled while waiting for its turn", append(
+						attrs,
+						slog.String("decision", "canceled"),
+						slog.Int("status", http.StatusServiceUnavailable),
+						slog.Any("error", err),
+					)...)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"message": "the request was canceled while waiting for its turn"}`))
+					return
+				}
+				if err != nil {
+					decision.Allowed = o.allowedOnFailure(decision.Allowed)
+					status := http.StatusOK
+					if !decision.Allowed {
+						status = http.StatusServiceUnavailable
+					}
+					level := slog.LevelError
+					if errors.Is(err, limiter.ErrBreakerOpen) {
+						level = slog.LevelDebug
+					}
+					logger.LogAttrs(r.Context(), level, "rate limiter store error", append(
+						attrs,
+						slog.String("decision", decisionName(decision.Allowed)),
+						slog.Int("status", status),
+						slog.Any("error", err),
+					)...)
+					if !decision.Allowed {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						w.Write([]byte(`{"message": "the rate limiter is unavailable, try again later"}`))
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+				holder.update(decision)
+				writeRateLimitHeaders(w, holder.decision, o)
+				if !decision.Allowed {
+					logger.LogAttrs(r.Context(), slog.LevelInfo, "request limited", append(
+						attrs,
+						slog.String("decision", decisionName(false)),
+						slog.Int("status", http.StatusTooManyRequests),
+						slog.Int("limit", decision.Limit),
+						slog.Duration("retry_after", decision.RetryAfter.Round(time.Millisecond)),
+					)...)
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write([]byte(`{"message": "you have reached the maximum number of requests or actions allowed within a certain time frame"}`))
+					return
+				}
 			}
 			r = r.WithContext(context.WithValue(r.Context(), rateLimitAllowedKey, true))
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// decisionName returns how a decision is logged.
+func decisionName(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "limited"
+}
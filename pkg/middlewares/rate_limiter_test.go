@@ -1,15 +1,23 @@
 package middlewares
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
 	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/status"
 	"github.com/stretchr/testify/suite"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type RateLimiterMiddlewareTestSuite struct {
@@ -89,3 +97,369 @@ func (suite *RateLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenContextRateL
 	suite.middleware(suite.handler).ServeHTTP(rec2, req2)
 	suite.Equal(http.StatusOK, rec2.Code)
 }
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLeakyBucketMiddlewareWhenRequestIsQueuedThenShouldWaitAndReturnStatusOk() {
+	store := memory.NewMemoryStore()
+	middleware := NewRateLimiterMiddleware(limiter.NewLeakyBucketLimiter(store, 10, time.Second, time.Second, 1), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.4:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+
+	// Second request waits for its turn
+	start := time.Now()
+	rec2 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec2, req1)
+	suite.Equal(http.StatusOK, rec2.Code)
+	suite.GreaterOrEqual(time.Since(start), 90*time.Millisecond)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLeakyBucketMiddlewareWhenQueueIsFullThenShouldReturnStatusTooManyRequests() {
+	store := memory.NewMemoryStore()
+	middleware := NewRateLimiterMiddleware(limiter.NewLeakyBucketLimiter(store, 1, time.Minute, time.Hour, 0), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.5:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+
+	rec2 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec2, req1)
+	suite.Equal(http.StatusTooManyRequests, rec2.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLeakyBucketMiddlewareWhenRequestIsCanceledWhileQueuedThenShouldReturnStatusServiceUnavailable() {
+	store := memory.NewMemoryStore()
+	middleware := NewRateLimiterMiddleware(limiter.NewLeakyBucketLimiter(store, 1, time.Minute, time.Hour, 10), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.6:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+
+	ctx, cancel := context.WithTimeout(req1.Context(), 10*time.Millisecond)
+	defer cancel()
+	rec2 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec2, req1.WithContext(ctx))
+	suite.Equal(http.StatusServiceUnavailable, rec2.Code)
+}
+
+// failingStore is a limiter.Store that cannot be reached.
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Increment(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) IncrementBy(ctx context.Context, key string, n int) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Reset(ctx context.Context, key string) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Set(ctx context.Context, key string, s *status.Status) (*status.Status, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Take(ctx context.Context, key string, ttl time.Duration, take func(s *status.Status) bool) (*status.Status, bool, error) {
+	return nil, false, errors.New("store unavailable")
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenStoreCannotBeReachedThenShouldReturnStatusOk() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(failingStore{}, 1, time.Second), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.7:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenFailClosedMiddlewareWhenStoreCannotBeReachedThenShouldReturnStatusServiceUnavailable() {
+	l := limiter.NewLimiter(failingStore{}, 1, time.Second)
+	l.SetFailurePolicy(limiter.FailClosed)
+	middleware := NewRateLimiterMiddleware(l, nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.8:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusServiceUnavailable, rec1.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenFailClosedOptionWhenStoreCannotBeReachedThenShouldReturnStatusServiceUnavailable() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(failingStore{}, 1, time.Second), nil, WithFailurePolicy(limiter.FailClosed))
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.8:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusServiceUnavailable, rec1.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenFailOpenOptionWhenFailClosedLimiterStoreCannotBeReachedThenShouldReturnStatusOk() {
+	l := limiter.NewLimiter(failingStore{}, 1, time.Second)
+	l.SetFailurePolicy(limiter.FailClosed)
+	middleware := NewRateLimiterMiddleware(l, nil, WithFailurePolicy(limiter.FailOpen))
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.8:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenRequestIsAllowedThenShouldWriteRateLimitHeaders() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(memory.NewMemoryStore(), 2, time.Minute), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.9:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+	suite.Equal("2", rec1.Header().Get("X-RateLimit-Limit"))
+	suite.Equal("1", rec1.Header().Get("X-RateLimit-Remaining"))
+	reset, err := strconv.ParseInt(rec1.Header().Get("X-RateLimit-Reset"), 10, 64)
+	suite.NoError(err)
+	suite.InDelta(time.Now().Add(time.Minute).Unix(), reset, 1)
+	suite.Empty(rec1.Header().Get("Retry-After"))
+	suite.Empty(rec1.Header().Get("RateLimit"))
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenMiddlewareWhenRequestIsLimitedThenShouldWriteRetryAfterHeader() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(memory.NewMemoryStore(), 1, time.Minute), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.10:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+
+	rec2 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec2, req1)
+	suite.Equal(http.StatusTooManyRequests, rec2.Code)
+	suite.Equal("0", rec2.Header().Get("X-RateLimit-Remaining"))
+	suite.Equal("60", rec2.Header().Get("Retry-After"))
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenIETFHeadersOptionWhenRequestIsAllowedThenShouldWriteRateLimitFields() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(memory.NewMemoryStore(), 5, time.Minute), nil, WithIETFHeaders())
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.11:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+	suite.Equal(`"default";q=5;w=60`, rec1.Header().Get("RateLimit-Policy"))
+	suite.Equal(`"default";r=4;t=60`, rec1.Header().Get("RateLimit"))
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenChainedMiddlewaresWhenRequestIsDecidedThenHeadersReportTheLimiterThatDecided() {
+	apiKey := limiter.NewLimiter(memory.NewMemoryStore(), 10, time.Minute)
+	apiKey.SetRule("api_key")
+	ip := limiter.NewLimiter(memory.NewMemoryStore(), 1, time.Minute)
+	ip.SetRule("ip")
+	apiKeyMapper := func(r *http.Request) string {
+		return r.Header.Get("API_KEY")
+	}
+	chain := NewRateLimiterMiddleware(apiKey, apiKeyMapper, WithIETFHeaders())(
+		NewRateLimiterMiddleware(ip, nil, WithIETFHeaders())(suite.handler),
+	)
+
+	// The API key rule decides, the IP rule skips the requests
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.168.0.12:12345"
+		req.Header.Set("API_KEY", "abc123")
+		rec := httptest.NewRecorder()
+		chain.ServeHTTP(rec, req)
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("10", rec.Header().Get("X-RateLimit-Limit"))
+		suite.Contains(rec.Header().Get("RateLimit-Policy"), `"api_key"`)
+	}
+
+	// Without the API key the IP rule decides
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.0.12:12345"
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, req)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("1", rec.Header().Get("X-RateLimit-Limit"))
+	suite.Equal("0", rec.Header().Get("X-RateLimit-Remaining"))
+	suite.Contains(rec.Header().Get("RateLimit-Policy"), `"ip"`)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenQuotaHeldByPreviousMiddlewareWhenRequestIsAllowedThenShouldReportMostRestrictive() {
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(memory.NewMemoryStore(), 5, time.Minute), nil)
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.12:12345"
+	holder := &decisionHolder{
+		decision: limiter.Decision{
+			Allowed: true,
+			Rule:    "previous",
+			Quota:   limiter.Quota{Limit: 10, Remaining: 1, ResetAt: time.Now().Add(time.Second)},
+		},
+		set: true,
+	}
+	req1 = req1.WithContext(context.WithValue(req1.Context(), rateLimitDecisionKey, holder))
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+	suite.Equal("10", rec1.Header().Get("X-RateLimit-Limit"))
+	suite.Equal("1", rec1.Header().Get("X-RateLimit-Remaining"))
+	suite.Equal("previous", holder.decision.Rule)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenChainedMiddlewaresWhenFirstFailsOpenThenHeadersReportTheNextLimiterChecked() {
+	failing := limiter.NewLimiter(failingStore{}, 1, time.Minute)
+	failing.SetRule("failing")
+	ip := limiter.NewLimiter(memory.NewMemoryStore(), 3, time.Minute)
+	ip.SetRule("ip")
+	held := &decisionHolder{
+		decision: limiter.Decision{Allowed: true, Rule: "previous", Quota: limiter.Quota{Limit: 10, Remaining: 5}},
+		set:      true,
+	}
+	chain := NewRateLimiterMiddleware(failing, nil)(NewRateLimiterMiddleware(ip, nil)(suite.handler))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.0.12:12345"
+	req = req.WithContext(context.WithValue(req.Context(), rateLimitDecisionKey, held))
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, req)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("3", rec.Header().Get("X-RateLimit-Limit"))
+	suite.Equal("2", rec.Header().Get("X-RateLimit-Remaining"))
+	suite.Equal("ip", held.decision.Rule)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLimiterRuleWhenRequestIsAllowedThenShouldNameRateLimitPolicyAfterIt() {
+	l := limiter.NewLimiter(memory.NewMemoryStore(), 5, time.Minute)
+	l.SetRule("ip")
+	middleware := NewRateLimiterMiddleware(l, nil, WithIETFHeaders())
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.13:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+	suite.Equal(`"ip";q=5;w=60`, rec1.Header().Get("RateLimit-Policy"))
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenCostFunctionWhenRequestIsExecutedThenShouldConsumeItsCost() {
+	cost := func(r *http.Request) int {
+		if r.URL.Path == "/expensive" {
+			return 5
+		}
+		return 1
+	}
+	middleware := NewRateLimiterMiddleware(limiter.NewLimiter(memory.NewMemoryStore(), 6, time.Minute), nil, WithCost(cost))
+	req1 := httptest.NewRequest(http.MethodGet, "/expensive", nil)
+	req1.RemoteAddr = "192.168.0.14:12345"
+	req2 := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req2.RemoteAddr = "192.168.0.14:12345"
+
+	rec1 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec1, req1)
+	suite.Equal(http.StatusOK, rec1.Code)
+	suite.Equal("1", rec1.Header().Get("X-RateLimit-Remaining"))
+
+	// The expensive request no longer fits while a cheap one does
+	rec2 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec2, req1)
+	suite.Equal(http.StatusTooManyRequests, rec2.Code)
+
+	rec3 := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec3, req2)
+	suite.Equal(http.StatusOK, rec3.Code)
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenTracedRequestWhenRequestIsCheckedThenLimiterSpanIsChildOfRequestSpan() {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	l := limiter.NewLimiter(memory.NewMemoryStore(), 1, time.Second)
+	l.SetTracerProvider(provider)
+
+	reqCtx, span := provider.Tracer("test").Start(context.Background(), "request")
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+	req1.RemoteAddr = "192.168.0.9:12345"
+	rec1 := httptest.NewRecorder()
+	NewRateLimiterMiddleware(l, nil)(suite.handler).ServeHTTP(rec1, req1)
+	span.End()
+
+	spans := recorder.Ended()
+	suite.Require().Len(spans, 2)
+	suite.Equal("rate_limiter.check", spans[0].Name())
+	suite.Equal(span.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLoggerWhenRequestIsLimitedThenShouldLogItsFields() {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	l := limiter.NewLimiter(memory.NewMemoryStore(), 1, time.Second)
+	l.SetRule("ip")
+	handler := NewLogRequestMiddleware(logger)(NewRateLimiterMiddleware(l, nil, WithLogger(logger))(suite.handler))
+
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.10:12345"
+	handler.ServeHTTP(httptest.NewRecorder(), req1)
+	suite.Empty(buf.String())
+	handler.ServeHTTP(httptest.NewRecorder(), req1)
+
+	lines := logLines(&buf)
+	suite.Require().Len(lines, 1)
+	suite.Equal("request limited", lines[0]["msg"])
+	suite.Equal("INFO", lines[0]["level"])
+	suite.NotEmpty(lines[0]["request_id"])
+	suite.Equal("IP:192.168.0.10", lines[0]["key"])
+	suite.Equal("ip", lines[0]["rule"])
+	suite.Equal("limited", lines[0]["decision"])
+	suite.Equal(float64(http.StatusTooManyRequests), lines[0]["status"])
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenLoggerWhenRemoteAddrIsInvalidThenShouldLogParseError() {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	l := limiter.NewLimiter(memory.NewMemoryStore(), 1, time.Second)
+
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "invalid"
+	NewRateLimiterMiddleware(l, nil, WithLogger(logger))(suite.handler).ServeHTTP(httptest.NewRecorder(), req1)
+
+	lines := logLines(&buf)
+	suite.Require().Len(lines, 1)
+	suite.Equal("WARN", lines[0]["level"])
+	suite.Equal("invalid", lines[0]["remote_addr"])
+}
+
+func (suite *RateLimiterMiddlewareTestSuite) TestGivenBreakerOpenWhenStoreCannotBeReachedThenShouldLogAtDebugLevel() {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	l := limiter.NewLimiter(failingStore{}, 1, time.Second)
+	breaker := limiter.NewBreaker(1, time.Minute)
+	breaker.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	l.SetBreaker(breaker)
+	handler := NewRateLimiterMiddleware(l, nil, WithLogger(logger))(suite.handler)
+
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.RemoteAddr = "192.168.0.11:12345"
+	handler.ServeHTTP(httptest.NewRecorder(), req1)
+	handler.ServeHTTP(httptest.NewRecorder(), req1)
+
+	lines := logLines(&buf)
+	suite.Require().Len(lines, 2)
+	suite.Equal("ERROR", lines[0]["level"])
+	suite.Equal("store unavailable", lines[0]["error"])
+	suite.Equal("DEBUG", lines[1]["level"])
+	suite.Equal(limiter.ErrBreakerOpen.Error(), lines[1]["error"])
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import "net/http"
+
+// responseRecorder wraps a http.ResponseWriter recording the status code written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// newResponseRecorder returns a new responseRecorder with the status code
+// defaulting to http.StatusOK, as used when the handler never writes it.
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records the status code and writes it to the wrapped http.ResponseWriter.
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, used by http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
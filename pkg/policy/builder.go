@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/middlewares"
+)
+
+// Store represents a store supporting the algorithms of every rule.
+type Store interface {
+	limiter.LogStore
+	limiter.TATStore
+}
+
+// keyMapper returns the function extracting the key of the requests
+// described by key: "ip", "header:<name>" or "global".
+//
+// The functions return an empty string if the request has no such key.
+func keyMapper(key string) (func(*http.Request) string, error) {
+	switch {
+	case key == "ip":
+		return func(r *http.Request) string {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				return ""
+			}
+			return ip
+		}, nil
+	case key == "global":
+		return func(*http.Request) string { return "global" }, nil
+	case strings.HasPrefix(key, "header:") && len(key) > len("header:"):
+		header := strings.TrimPrefix(key, "header:")
+		return func(r *http.Request) string { return r.Header.Get(header) }, nil
+	default:
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+}
+
+// limiterKey returns the key of the limiter of the rule for a request key.
+func (r *Rule) limiterKey(key string) string {
+	return fmt.Sprintf("%s:%s", r.Name, key)
+}
+
+// matches returns true if the request matches the routes and methods of the rule.
+func (r *Rule) matches(req *http.Request) bool {
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(method string) bool {
+		return strings.EqualFold(method, req.Method)
+	}) {
+		return false
+	}
+	if len(r.Routes) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Routes, func(route string) bool {
+		matched, _ := path.Match(route, req.URL.Path)
+		return matched
+	})
+}
+
+// NewLimiter returns a new limiter with the algorithm and settings of the rule,
+// identified by the rule name.
+func (r *Rule) NewLimiter(store Store) *limiter.Limiter {
+	window := time.Duration(r.Window)
+	burst := r.burst()
+	var l *limiter.Limiter
+	switch r.Algorithm {
+	case TokenBucket:
+		l = limiter.NewTokenBucketLimiter(store, r.Limit, window, burst)
+	case SlidingLog:
+		l = limiter.NewSlidingLogLimiter(store, r.Limit, window)
+	case SlidingWindow:
+		l = limiter.NewSlidingWindowLimiter(store, r.Limit, window)
+	case GCRA:
+		l = limiter.NewGCRALimiter(store, r.Limit, window, burst)
+	case LeakyBucket:
+		l = limiter.NewLeakyBucketLimiter(store, r.Limit, window, time.Duration(r.MaxWait), r.QueueDepth)
+	default:
+		l = limiter.NewLimiter(store, r.Limit, window)
+	}
+	l.SetRule(r.Name)
+	return l
+}
+
+// Build returns a middleware chaining the rate limiter middlewares of the
+// rules from the highest to the lowest priority, along with the limiters of
+// the rules by name, for example to set their failure policy.
+//
+// The limiters share an overrides registry with the tiers of the policy and
+// the keys overridden by the rules, keyed by their limiter keys.
+//
+// Each rule only checks the requests matching its routes and methods, keyed
+// by its name and key, so the rule with the highest priority matching a
+// request with a key decides it. The options are used by every middleware.
+func Build(p *Policy, store Store, opts ...middlewares.Option) (func(http.Handler) http.Handler, map[string]*limiter.Limiter, error) {
+	c, err := build(p, store, nil, nil, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.middleware, c.limiters, nil
+}
+
+// chain represents the middleware chain built from a policy.
+type chain struct {
+	middleware func(http.Handler) http.Handler
+	limiters   map[string]*limiter.Limiter
+	rules      map[string]Rule
+	overrides  *limiter.Overrides
+}
+
+// build returns the middleware chain of the policy as Build does.
+//
+// The limiters of the previous chain are kept for the rules with the same
+// name and algorithm settings, updating their limit and window, while the
+// limiters created are passed to setup, if any. The overrides registry of the
+// previous chain is also kept, replacing its tiers and keys.
+func build(p *Policy, store Store, previous *chain, setup func(*limiter.Limiter), opts []middlewares.Option) (*chain, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	rules := slices.Clone(p.Rules)
+	slices.SortStableFunc(rules, func(a, b Rule) int {
+		return a.Priority - b.Priority
+	})
+
+	c := &chain{
+		limiters:  make(map[string]*limiter.Limiter, len(rules)),
+		rules:     make(map[string]Rule, len(rules)),
+		overrides: limiter.NewOverrides(),
+	}
+	if previous != nil {
+		c.overrides = previous.overrides
+	} else {
+		c.overrides.SetLogger(middlewares.Logger(opts...))
+	}
+	tiers := make([]limiter.Tier, 0, len(p.Tiers))
+	for _, tier := range p.Tiers {
+		tiers = append(tiers, limiter.Tier{Name: tier.Name, Limit: tier.Limit, Duration: time.Duration(tier.Window)})
+	}
+	keys := make(map[string]string)
+	for _, rule := range rules {
+		for key, tier := range rule.Overrides {
+			keys[rule.limiterKey(key)] = tier
+		}
+	}
+	if err := c.overrides.Replace(tiers, keys); err != nil {
+		return nil, err
+	}
+
+	handlers := make([]func(http.Handler) http.Handler, 0, len(rules))
+	for _, rule := range rules {
+		extract, err := keyMapper(rule.Key)
+		if err != nil {
+			return nil, err
+		}
+		var l *limiter.Limiter
+		if previous != nil && previous.rules[rule.Name].sameAlgorithm(rule) {
+			l = previous.limiters[rule.Name]
+			l.SetLimit(rule.Limit)
+			l.SetDuration(time.Duration(rule.Window))
+		} else {
+			l = rule.NewLimiter(store)
+			l.SetOverrides(c.overrides)
+			if setup != nil {
+				setup(l)
+			}
+		}
+		c.limiters[rule.Name] = l
+		c.rules[rule.Name] = rule
+		handlers = append(handlers, middlewares.NewRateLimiterMiddleware(l, func(r *http.Request) string {
+			if !rule.matches(r) {
+				return ""
+			}
+			key := extract(r)
+			if key == "" {
+				return ""
+			}
+			return rule.limiterKey(key)
+		}, opts...))
+	}
+	c.middleware = func(next http.Handler) http.Handler {
+		for _, handler := range handlers {
+			next = handler(next)
+		}
+		return next
+	}
+	return c, nil
+}
+
+// burst returns the burst of the rule, which is the limit if not set.
+func (r Rule) burst() int {
+	if r.Burst <= 0 {
+		return r.Limit
+	}
+	return r.Burst
+}
+
+// sameAlgorithm returns true if both rules have the same name and the same
+// algorithm settings, besides the limit and window.
+func (r Rule) sameAlgorithm(other Rule) bool {
+	if r.Name != other.Name || r.Algorithm != other.Algorithm {
+		return false
+	}
+	switch r.Algorithm {
+	case TokenBucket, GCRA:
+		return r.burst() == other.burst()
+	case LeakyBucket:
+		return r.MaxWait == other.MaxWait && r.QueueDepth == other.QueueDepth
+	default:
+		return true
+	}
+}
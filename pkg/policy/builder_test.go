@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type BuilderTestSuite struct {
+	suite.Suite
+	handler http.Handler
+	store   *memory.MemoryStore
+}
+
+func (suite *BuilderTestSuite) SetupTest() {
+	suite.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestBuilderSuite(t *testing.T) {
+	suite.Run(t, new(BuilderTestSuite))
+}
+
+func (suite *BuilderTestSuite) serve(middleware func(http.Handler) http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "192.168.0.1:12345"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	middleware(suite.handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func (suite *BuilderTestSuite) TestGivenRulesWhenCallingBuildThenLimitersAreCreatedByName() {
+	p := &Policy{Rules: []Rule{
+		{Name: "ip", Key: "ip", Limit: 10, Window: Duration(time.Second)},
+		{Name: "gcra", Key: "global", Algorithm: GCRA, Limit: 5, Window: Duration(time.Minute)},
+	}}
+	_, limiters, err := Build(p, suite.store)
+	suite.NoError(err)
+	suite.Len(limiters, 2)
+	suite.Equal(10, limiters["ip"].Limit())
+	suite.Equal("ip", limiters["ip"].Rule())
+	suite.Equal(5, limiters["gcra"].Limit())
+}
+
+func (suite *BuilderTestSuite) TestGivenInvalidPolicyWhenCallingBuildThenReturnError() {
+	p := &Policy{Rules: []Rule{{Name: "ip", Key: "ip"}}}
+	_, _, err := Build(p, suite.store)
+	suite.Error(err)
+}
+
+func (suite *BuilderTestSuite) TestGivenRulesWithPrioritiesWhenRequestHasKeyThenHighestPriorityRuleDecides() {
+	p := &Policy{Rules: []Rule{
+		{Name: "ip", Key: "ip", Limit: 1, Window: Duration(time.Minute)},
+		{Name: "api_key", Key: "header:API_KEY", Limit: 3, Window: Duration(time.Minute), Priority: 1},
+	}}
+	middleware, _, err := Build(p, suite.store)
+	suite.NoError(err)
+
+	withAPIKey := http.Header{"Api_key": {"abc"}}
+	for range 3 {
+		suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodGet, "/hello", withAPIKey).Code)
+	}
+	suite.Equal(http.StatusTooManyRequests, suite.serve(middleware, http.MethodGet, "/hello", withAPIKey).Code)
+
+	// Without the API key the lower priority rule decides
+	suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodGet, "/hello", nil).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.serve(middleware, http.MethodGet, "/hello", nil).Code)
+}
+
+func (suite *BuilderTestSuite) TestGivenRuleWithRoutesAndMethodsWhenRequestDoesNotMatchThenRuleIsSkipped() {
+	p := &Policy{Rules: []Rule{
+		{Name: "uploads", Key: "ip", Limit: 1, Window: Duration(time.Minute), Routes: []string{"/upload/*"}, Methods: []string{"post"}},
+	}}
+	middleware, _, err := Build(p, suite.store)
+	suite.NoError(err)
+
+	suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodPost, "/upload/a", nil).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.serve(middleware, http.MethodPost, "/upload/b", nil).Code)
+	suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodGet, "/upload/a", nil).Code)
+	suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodPost, "/hello", nil).Code)
+}
+
+func (suite *BuilderTestSuite) TestGivenKeyOverriddenByTierWhenRequestIsExecutedThenTierLimitIsUsed() {
+	p := &Policy{
+		Tiers: []Tier{{Name: "enterprise", Limit: 3, Window: Duration(time.Minute)}},
+		Rules: []Rule{{
+			Name:      "api_key",
+			Key:       "header:API_KEY",
+			Limit:     1,
+			Window:    Duration(time.Minute),
+			Overrides: map[string]string{"abc123": "enterprise"},
+		}},
+	}
+	middleware, limiters, err := Build(p, suite.store)
+	suite.NoError(err)
+	suite.Equal(map[string]string{"api_key:abc123": "enterprise"}, limiters["api_key"].Overrides().Keys())
+
+	enterprise := http.Header{"Api_key": {"abc123"}}
+	for range 3 {
+		suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodGet, "/hello", enterprise).Code)
+	}
+	suite.Equal(http.StatusTooManyRequests, suite.serve(middleware, http.MethodGet, "/hello", enterprise).Code)
+
+	other := http.Header{"Api_key": {"def456"}}
+	suite.Equal(http.StatusOK, suite.serve(middleware, http.MethodGet, "/hello", other).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.serve(middleware, http.MethodGet, "/hello", other).Code)
+}
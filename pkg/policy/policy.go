@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Algorithms supported by the rules.
+const (
+	FixedWindow   = "fixed_window"
+	TokenBucket   = "token_bucket"
+	SlidingLog    = "sliding_log"
+	SlidingWindow = "sliding_window"
+	GCRA          = "gcra"
+	LeakyBucket   = "leaky_bucket"
+)
+
+// Policy represents a set of named rate limiting rules.
+type Policy struct {
+	Tiers []Tier `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Tier represents a plan overriding the limit and window of the rules
+// for the keys assigned to it.
+type Tier struct {
+	// Name identifies the tier, it must be unique in the policy.
+	Name string `json:"name" yaml:"name"`
+	// Limit is the number of requests allowed per window.
+	Limit int `json:"limit" yaml:"limit"`
+	// Window is the duration in which the limit is enforced.
+	Window Duration `json:"window" yaml:"window"`
+}
+
+// Rule represents a rate limiting rule.
+type Rule struct {
+	// Name identifies the rule, it must be unique in the policy.
+	Name string `json:"name" yaml:"name"`
+	// Key extracts the key of the requests: "ip", "header:<name>" or "global".
+	// The limiter keys are the rule name and the IP, header value or "global",
+	// as in "api_key:abc123".
+	Key string `json:"key" yaml:"key"`
+	// Algorithm is the algorithm of the limiter, fixed_window by default.
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	// Limit is the number of requests allowed per window.
+	Limit int `json:"limit" yaml:"limit"`
+	// Window is the duration in which the limit is enforced, as "1s" or "1m".
+	Window Duration `json:"window" yaml:"window"`
+	// Burst is the burst of the token_bucket and gcra algorithms, the limit by default.
+	Burst int `json:"burst,omitempty" yaml:"burst,omitempty"`
+	// MaxWait is the maximum wait of the leaky_bucket algorithm.
+	MaxWait Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty"`
+	// QueueDepth is the queue depth of the leaky_bucket algorithm.
+	QueueDepth int `json:"queue_depth,omitempty" yaml:"queue_depth,omitempty"`
+	// Routes are the path patterns of the requests matched by the rule, as in
+	// path.Match, all the paths are matched if empty.
+	Routes []string `json:"routes,omitempty" yaml:"routes,omitempty"`
+	// Methods are the methods of the requests matched by the rule, all the
+	// methods are matched if empty.
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	// Priority orders the rules, the rule with the highest priority matching
+	// a request with a key decides it.
+	Priority int `json:"priority" yaml:"priority"`
+	// Overrides assigns keys, as the IP or header value, to tiers.
+	Overrides map[string]string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// Duration represents a duration written as a string such as "1s" or "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration from a JSON string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return d.parse(value)
+}
+
+// MarshalJSON writes the duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalYAML parses the duration from a YAML string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return d.parse(value)
+}
+
+// MarshalYAML writes the duration as a YAML string.
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) parse(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Load reads the policy from a JSON file if its extension is .json,
+// otherwise from a YAML file, and validates it.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &p)
+	} else {
+		err = yaml.Unmarshal(data, &p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing policy %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Validate returns an error if any tier or rule of the policy is invalid,
+// two of them have the same name or a rule overrides a key with a missing tier.
+func (p *Policy) Validate() error {
+	var errs []error
+	tiers := make(map[string]bool)
+	for _, tier := range p.Tiers {
+		if err := tier.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if tiers[tier.Name] {
+			errs = append(errs, fmt.Errorf("tier %q: duplicated name", tier.Name))
+		}
+		tiers[tier.Name] = true
+	}
+	names := make(map[string]bool)
+	for _, rule := range p.Rules {
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if names[rule.Name] {
+			errs = append(errs, fmt.Errorf("rule %q: duplicated name", rule.Name))
+		}
+		names[rule.Name] = true
+		for key, tier := range rule.Overrides {
+			if !tiers[tier] {
+				errs = append(errs, fmt.Errorf("rule %q: key %q overridden by unknown tier %q", rule.Name, key, tier))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error if the tier is invalid.
+func (t *Tier) Validate() error {
+	if t.Name == "" {
+		return errors.New("tier without name")
+	}
+	if t.Limit <= 0 {
+		return fmt.Errorf("tier %q: limit must be positive", t.Name)
+	}
+	if t.Window <= 0 {
+		return fmt.Errorf("tier %q: window must be positive", t.Name)
+	}
+	return nil
+}
+
+// Validate returns an error if the rule is invalid.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule without name")
+	}
+	if _, err := keyMapper(r.Key); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	switch r.Algorithm {
+	case "", FixedWindow, TokenBucket, SlidingLog, SlidingWindow, GCRA, LeakyBucket:
+	default:
+		return fmt.Errorf("rule %q: unknown algorithm %q", r.Name, r.Algorithm)
+	}
+	if r.Limit <= 0 {
+		return fmt.Errorf("rule %q: limit must be positive", r.Name)
+	}
+	if r.Window <= 0 {
+		return fmt.Errorf("rule %q: window must be positive", r.Name)
+	}
+	return nil
+}
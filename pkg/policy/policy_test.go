@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PolicyTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *PolicyTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+func TestPolicySuite(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
+
+func (suite *PolicyTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.dir, name)
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func (suite *PolicyTestSuite) TestGivenYAMLFileWhenCallingLoadThenRulesAreParsed() {
+	path := suite.writeFile("policy.yaml", `
+rules:
+  - name: uploads
+    key: header:API_KEY
+    algorithm: token_bucket
+    limit: 5
+    window: 1m
+    burst: 10
+    routes: ["/upload/*"]
+    methods: [POST]
+    priority: 2
+`)
+	p, err := Load(path)
+	suite.NoError(err)
+	suite.Equal([]Rule{{
+		Name:      "uploads",
+		Key:       "header:API_KEY",
+		Algorithm: TokenBucket,
+		Limit:     5,
+		Window:    Duration(time.Minute),
+		Burst:     10,
+		Routes:    []string{"/upload/*"},
+		Methods:   []string{"POST"},
+		Priority:  2,
+	}}, p.Rules)
+}
+
+func (suite *PolicyTestSuite) TestGivenJSONFileWhenCallingLoadThenRulesAreParsed() {
+	path := suite.writeFile("policy.json", `{"rules": [
+		{"name": "queue", "key": "global", "algorithm": "leaky_bucket", "limit": 10, "window": "1s", "max_wait": "500ms", "queue_depth": 3}
+	]}`)
+	p, err := Load(path)
+	suite.NoError(err)
+	suite.Equal([]Rule{{
+		Name:       "queue",
+		Key:        "global",
+		Algorithm:  LeakyBucket,
+		Limit:      10,
+		Window:     Duration(time.Second),
+		MaxWait:    Duration(500 * time.Millisecond),
+		QueueDepth: 3,
+	}}, p.Rules)
+}
+
+func (suite *PolicyTestSuite) TestGivenTiersAndOverridesWhenCallingLoadThenTheyAreParsed() {
+	path := suite.writeFile("policy.yaml", `
+tiers:
+  - name: enterprise
+    limit: 10000
+    window: 1m
+rules:
+  - name: api_key
+    key: header:API_KEY
+    limit: 100
+    window: 1s
+    overrides:
+      abc123: enterprise
+`)
+	p, err := Load(path)
+	suite.NoError(err)
+	suite.Equal([]Tier{{Name: "enterprise", Limit: 10000, Window: Duration(time.Minute)}}, p.Tiers)
+	suite.Equal(map[string]string{"abc123": "enterprise"}, p.Rules[0].Overrides)
+}
+
+func (suite *PolicyTestSuite) TestGivenInvalidDurationWhenCallingLoadThenReturnError() {
+	path := suite.writeFile("policy.yaml", `
+rules:
+  - name: ip
+    key: ip
+    limit: 5
+    window: 5
+`)
+	_, err := Load(path)
+	suite.ErrorContains(err, "error parsing policy")
+}
+
+func (suite *PolicyTestSuite) TestGivenMissingFileWhenCallingLoadThenReturnError() {
+	_, err := Load(filepath.Join(suite.dir, "missing.yaml"))
+	suite.ErrorIs(err, os.ErrNotExist)
+}
+
+func (suite *PolicyTestSuite) TestGivenInvalidTiersWhenCallingValidateThenReturnEveryError() {
+	p := Policy{
+		Tiers: []Tier{
+			{Name: "pro", Limit: 1, Window: Duration(time.Second)},
+			{Name: "pro", Limit: 1, Window: Duration(time.Second)},
+			{Name: "zero", Window: Duration(time.Second)},
+		},
+		Rules: []Rule{
+			{Name: "ip", Key: "ip", Limit: 1, Window: Duration(time.Second), Overrides: map[string]string{"10.0.0.1": "missing"}},
+		},
+	}
+	err := p.Validate()
+	suite.ErrorContains(err, `tier "pro": duplicated name`)
+	suite.ErrorContains(err, `tier "zero": limit must be positive`)
+	suite.ErrorContains(err, `rule "ip": key "10.0.0.1" overridden by unknown tier "missing"`)
+}
+
+func (suite *PolicyTestSuite) TestGivenInvalidRulesWhenCallingValidateThenReturnEveryError() {
+	p := Policy{Rules: []Rule{
+		{Name: "ip", Key: "ip", Limit: 1, Window: Duration(time.Second)},
+		{Name: "ip", Key: "ip", Limit: 1, Window: Duration(time.Second)},
+		{Name: "cookie", Key: "cookie:session", Limit: 1, Window: Duration(time.Second)},
+		{Name: "unknown", Key: "ip", Algorithm: "unknown", Limit: 1, Window: Duration(time.Second)},
+		{Name: "zero", Key: "ip", Window: Duration(time.Second)},
+		{Name: "instant", Key: "ip", Limit: 1},
+		{Key: "ip", Limit: 1, Window: Duration(time.Second)},
+	}}
+	err := p.Validate()
+	suite.ErrorContains(err, `rule "ip": duplicated name`)
+	suite.ErrorContains(err, `rule "cookie": unknown key "cookie:session"`)
+	suite.ErrorContains(err, `rule "unknown": unknown algorithm "unknown"`)
+	suite.ErrorContains(err, `rule "zero": limit must be positive`)
+	suite.ErrorContains(err, `rule "instant": window must be positive`)
+	suite.ErrorContains(err, "rule without name")
+}
@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/middlewares"
+)
+
+// Reloader serves the middleware chain of a policy file, which can be
+// reloaded without restarting the server.
+//
+// Reloading swaps the chain atomically, keeping the limiters and counters of
+// the rules with the same name and algorithm settings, while an invalid
+// policy is rejected keeping the previous chain.
+type Reloader struct {
+	path    string
+	store   Store
+	setup   func(*limiter.Limiter)
+	opts    []middlewares.Option
+	logger  *slog.Logger
+	chain   atomic.Pointer[chain]
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewReloader returns a new reloader with the policy loaded from the file.
+//
+// The setup function, if any, is called with every limiter created, for
+// example to set its failure policy, and the options are used by every
+// middleware. The reloads and the tier lookup errors of the overrides are
+// logged to the logger of the options, if any, or slog.Default().
+// It returns an error if the policy could not be loaded.
+func NewReloader(path string, store Store, setup func(*limiter.Limiter), opts ...middlewares.Option) (*Reloader, error) {
+	r := &Reloader{path: path, store: store, setup: setup, opts: opts, logger: middlewares.Logger(opts...)}
+	c, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.chain.Store(c)
+	return r, nil
+}
+
+// Reload loads the policy file again and swaps the middleware chain.
+//
+// It returns an error if the policy is invalid, in which case the previous
+// chain is kept. Each reload is logged.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.load()
+	if err != nil {
+		r.logger.Error("error reloading policy, keeping the previous one", slog.String("path", r.path), slog.Any("error", err))
+		return err
+	}
+	r.chain.Store(c)
+	r.logger.Info("policy reloaded", slog.String("path", r.path), slog.Int("rules", len(c.rules)))
+	return nil
+}
+
+// load returns the middleware chain of the policy file, reusing the
+// limiters of the current chain, and keeps the modification time of the file.
+func (r *Reloader) load() (*chain, error) {
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	p, err := Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+	return build(p, r.store, r.chain.Load(), r.setup, r.opts)
+}
+
+// Overrides returns the overrides registry shared by the limiters, which is
+// kept across reloads replacing its tiers and keys by the ones of the policy.
+func (r *Reloader) Overrides() *limiter.Overrides {
+	return r.chain.Load().overrides
+}
+
+// Limiters returns the limiters of the current policy rules by name.
+func (r *Reloader) Limiters() map[string]*limiter.Limiter {
+	return r.chain.Load().limiters
+}
+
+// Middleware returns the middleware chain of the current policy.
+func (r *Reloader) Middleware(next http.Handler) http.Handler {
+	type handler struct {
+		chain *chain
+		http.Handler
+	}
+	var current atomic.Pointer[handler]
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := r.chain.Load()
+		h := current.Load()
+		if h == nil || h.chain != c {
+			h = &handler{chain: c, Handler: c.middleware(next)}
+			current.Store(h)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ReloadOnSignal reloads the policy each time one of the signals is received,
+// usually syscall.SIGHUP, until the context is done.
+func (r *Reloader) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	go func() {
+		defer signal.Stop(received)
+		for {
+			select {
+			case <-received:
+				r.Reload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Watch reloads the policy each time the modification time of the file
+// changes, checking it every interval until the context is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				info, err := os.Stat(r.path)
+				if err != nil {
+					continue
+				}
+				r.mu.Lock()
+				changed := !info.ModTime().Equal(r.modTime)
+				r.mu.Unlock()
+				if changed {
+					r.Reload()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rcbadiale/go-rate-limiter/internal/stores/memory"
+	"github.com/rcbadiale/go-rate-limiter/pkg/limiter"
+	"github.com/rcbadiale/go-rate-limiter/pkg/middlewares"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReloaderTestSuite struct {
+	suite.Suite
+	path    string
+	handler http.Handler
+	store   *memory.MemoryStore
+}
+
+func (suite *ReloaderTestSuite) SetupTest() {
+	suite.path = filepath.Join(suite.T().TempDir(), "policy.yaml")
+	suite.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	suite.store = memory.NewMemoryStore()
+}
+
+func TestReloaderSuite(t *testing.T) {
+	suite.Run(t, new(ReloaderTestSuite))
+}
+
+func (suite *ReloaderTestSuite) writePolicy(content string) {
+	suite.Require().NoError(os.WriteFile(suite.path, []byte(content), 0o600))
+}
+
+func (suite *ReloaderTestSuite) serve(handler http.Handler) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.0.1:12345"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func (suite *ReloaderTestSuite) TestGivenLimitChangedWhenCallingReloadThenLimiterIsKeptWithNewLimit() {
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m"}]}`)
+	reloader, err := NewReloader(suite.path, suite.store, nil)
+	suite.Require().NoError(err)
+	handler := reloader.Middleware(suite.handler)
+	l := reloader.Limiters()["ip"]
+
+	suite.Equal(http.StatusOK, suite.serve(handler))
+	suite.Equal(http.StatusTooManyRequests, suite.serve(handler))
+
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 2, "window": "2m"}]}`)
+	suite.NoError(reloader.Reload())
+	suite.Same(l, reloader.Limiters()["ip"])
+	suite.Equal(2, l.Limit())
+	suite.Equal(2*time.Minute, l.Duration())
+
+	// The request counted before the reload is kept
+	suite.Equal(http.StatusOK, suite.serve(handler))
+	suite.Equal(http.StatusTooManyRequests, suite.serve(handler))
+}
+
+func (suite *ReloaderTestSuite) TestGivenOverridesEditedAtRuntimeWhenCallingReloadThenEditsAreKept() {
+	suite.writePolicy(`{"tiers": [{"name": "pro", "limit": 2, "window": "1m"}],
+		"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m", "overrides": {"10.0.0.1": "pro"}}]}`)
+	reloader, err := NewReloader(suite.path, suite.store, nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(reloader.Overrides().SetTier(limiter.Tier{Name: "enterprise", Limit: 3, Duration: time.Minute}))
+	reloader.Overrides().SetKey("ip:192.168.0.1", "enterprise")
+
+	suite.NoError(reloader.Reload())
+	suite.Equal(map[string]string{"ip:10.0.0.1": "pro", "ip:192.168.0.1": "enterprise"}, reloader.Overrides().Keys())
+	handler := reloader.Middleware(suite.handler)
+	for range 3 {
+		suite.Equal(http.StatusOK, suite.serve(handler))
+	}
+	suite.Equal(http.StatusTooManyRequests, suite.serve(handler))
+}
+
+func (suite *ReloaderTestSuite) TestGivenInvalidPolicyWhenCallingReloadThenPreviousPolicyIsKept() {
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m"}]}`)
+	reloader, err := NewReloader(suite.path, suite.store, nil)
+	suite.Require().NoError(err)
+	handler := reloader.Middleware(suite.handler)
+	l := reloader.Limiters()["ip"]
+
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 0, "window": "1m"}]}`)
+	suite.Error(reloader.Reload())
+	suite.Same(l, reloader.Limiters()["ip"])
+	suite.Equal(1, l.Limit())
+	suite.Equal(http.StatusOK, suite.serve(handler))
+	suite.Equal(http.StatusTooManyRequests, suite.serve(handler))
+}
+
+func (suite *ReloaderTestSuite) TestGivenLoggerWhenCallingReloadThenReloadsAreLoggedToTheLogger() {
+	var buf bytes.Buffer
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m"}]}`)
+	reloader, err := NewReloader(suite.path, suite.store, nil, middlewares.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	suite.Require().NoError(err)
+
+	suite.NoError(reloader.Reload())
+	suite.Contains(buf.String(), "policy reloaded")
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 0, "window": "1m"}]}`)
+	suite.Error(reloader.Reload())
+	suite.Contains(buf.String(), "error reloading policy")
+}
+
+func (suite *ReloaderTestSuite) TestGivenAlgorithmChangedWhenCallingReloadThenNewLimiterIsSetup() {
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m"}]}`)
+	var setup []*limiter.Limiter
+	reloader, err := NewReloader(suite.path, suite.store, func(l *limiter.Limiter) {
+		setup = append(setup, l)
+	})
+	suite.Require().NoError(err)
+	suite.Len(setup, 1)
+
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m", "algorithm": "gcra"}]}`)
+	suite.NoError(reloader.Reload())
+	suite.Len(setup, 2)
+	suite.Same(setup[1], reloader.Limiters()["ip"])
+}
+
+func (suite *ReloaderTestSuite) TestGivenInvalidPolicyWhenCallingNewReloaderThenReturnError() {
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "unknown", "limit": 1, "window": "1m"}]}`)
+	_, err := NewReloader(suite.path, suite.store, nil)
+	suite.Error(err)
+}
+
+func (suite *ReloaderTestSuite) TestGivenWatchWhenFileChangesThenPolicyIsReloaded() {
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 1, "window": "1m"}]}`)
+	reloader, err := NewReloader(suite.path, suite.store, nil)
+	suite.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloader.Watch(ctx, 10*time.Millisecond)
+
+	suite.writePolicy(`{"rules": [{"name": "ip", "key": "ip", "limit": 5, "window": "1m"}]}`)
+	suite.Require().NoError(os.Chtimes(suite.path, time.Now(), time.Now().Add(time.Second)))
+	suite.Eventually(func() bool {
+		return reloader.Limiters()["ip"].Limit() == 5
+	}, time.Second, 10*time.Millisecond)
+}
@@ -6,6 +6,9 @@ import "time"
 type Status struct {
 	Count     int
 	StartedAt time.Time
+	// PreviousCount is the count of the window before the current one,
+	// used by sliding window counters.
+	PreviousCount int
 }
 
 // NewStatus creates a new status.
@@ -26,3 +29,15 @@ func (s *Status) ReachedLimit(limit int) bool {
 func (s *Status) IsExpired(duration time.Duration) bool {
 	return time.Now().After(s.StartedAt.Add(duration))
 }
+
+// WeightedCount returns the count of the current window plus the count of
+// the previous window weighted by how much of it still overlaps a rolling
+// window of the given duration ending now.
+func (s *Status) WeightedCount(duration time.Duration) float64 {
+	if duration <= 0 {
+		return float64(s.Count)
+	}
+	elapsed := min(max(time.Since(s.StartedAt), 0), duration)
+	weight := 1 - float64(elapsed)/float64(duration)
+	return float64(s.PreviousCount)*weight + float64(s.Count)
+}
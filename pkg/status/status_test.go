@@ -26,3 +26,14 @@ func TestGivenADurationWhenCallingIsExpiredThenReturnTrueIfStatusIsExpired(t *te
 	assert.True(t, status.IsExpired(time.Minute))
 	assert.False(t, status.IsExpired(time.Hour))
 }
+
+func TestGivenADurationWhenCallingWeightedCountThenPreviousCountIsWeightedByOverlap(t *testing.T) {
+	status := &Status{Count: 2, PreviousCount: 10, StartedAt: time.Now().Add(-15 * time.Second)}
+	assert.InDelta(t, 9.5, status.WeightedCount(time.Minute), 0.1)
+}
+
+func TestGivenAStatusOlderThanDurationWhenCallingWeightedCountThenPreviousCountIsIgnored(t *testing.T) {
+	status := &Status{Count: 2, PreviousCount: 10, StartedAt: time.Now().Add(-time.Hour)}
+	assert.Equal(t, 2.0, status.WeightedCount(time.Minute))
+	assert.Equal(t, 2.0, status.WeightedCount(0))
+}